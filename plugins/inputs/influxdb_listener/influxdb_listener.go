@@ -1,20 +1,50 @@
 package influxdb_listener
 
 import (
+	"bufio"
+	"bytes"
 	"compress/gzip"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
 	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"log"
+	"math"
+	"math/bits"
+	"math/rand"
+	"mime"
 	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/filter"
 	"github.com/influxdata/telegraf/internal"
 	tlsint "github.com/influxdata/telegraf/internal/tls"
+	"github.com/influxdata/telegraf/metric"
 	"github.com/influxdata/telegraf/plugins/inputs"
+	"github.com/influxdata/telegraf/plugins/parsers/csv"
 	"github.com/influxdata/telegraf/plugins/parsers/influx"
+	"github.com/influxdata/telegraf/plugins/parsers/prometheus"
+	serializer "github.com/influxdata/telegraf/plugins/serializers/influx"
 	"github.com/influxdata/telegraf/selfstat"
 )
 
@@ -22,28 +52,288 @@ const (
 	// defaultMaxBodySize is the default maximum request body size, in bytes.
 	// if the request body is over this size, we will return an HTTP 413 error.
 	defaultMaxBodySize = 32 * 1024 * 1024
+
+	// defaultQueryMaxBodySize is the default maximum /query request body
+	// size, in bytes.
+	defaultQueryMaxBodySize = 1024 * 1024
+)
+
+var errLineTooLong = errors.New("http: line too long")
+
+// gzipMagic starts gzip streams using the deflate method.  It is not valid
+// UTF-8, so it cannot start valid line protocol.
+var gzipMagic = []byte{0x1f, 0x8b, 0x08}
+
+var errDecompressionRatio = errors.New("http: decompression ratio too high")
+
+var errTooManyDecompressions = errors.New("http: too many concurrent decompressions")
+
+var errBodyTooLarge = errors.New("http: request body too large")
+
+var errTooManyMetrics = errors.New("http: too many metrics in the request")
+
+// invalidNameChars matches the characters replaced in measurement names when
+// sanitize_measurement_names is set.
+var invalidNameChars = regexp.MustCompile(`[^A-Za-z0-9_.\-]`)
+
+// Timestamps are plausible between these times when detecting the
+// precision.  The range is narrow enough for only one of s, ms, us and ns to
+// give a plausible time for a timestamp.
+var (
+	minPlausibleTime = time.Date(1971, 1, 1, 0, 0, 0, 0, time.UTC)
+	maxPlausibleTime = time.Date(2200, 1, 1, 0, 0, 0, 0, time.UTC)
 )
 
+var errBacklogUnsupported = errors.New("listen_backlog is not supported on this platform")
+
+// FieldConversion is a linear transformation of a field value.
+type FieldConversion struct {
+	Scale  float64 `toml:"scale"`
+	Offset float64 `toml:"offset"`
+}
+
+// SNICert is a certificate and key served to clients asking for a server
+// name.
+type SNICert struct {
+	TLSCert string `toml:"tls_cert"`
+	TLSKey  string `toml:"tls_key"`
+}
+
+// RootResponse is the response to requests for the root path.
+type RootResponse struct {
+	Status int    `toml:"status"`
+	Body   string `toml:"body"`
+}
+
 type InfluxDBListener struct {
 	ServiceAddress string `toml:"service_address"`
 	port           int
 	tlsint.ServerConfig
+	RequireTLS        bool               `toml:"require_tls"`
+	StrictMethods     bool               `toml:"strict_methods"`
+	StrictQueryParams bool               `toml:"strict_query_params"`
+	TLSSNICerts       map[string]SNICert `toml:"tls_sni_certs"`
+	TLSCRLFile        string             `toml:"tls_crl_file"`
+
+	BlockedUserAgents []string `toml:"blocked_user_agents"`
+	RequiredUserAgent string   `toml:"required_user_agent"`
+
+	ReadTimeout                 internal.Duration `toml:"read_timeout"`
+	WriteTimeout                internal.Duration `toml:"write_timeout"`
+	MaxRequestDuration          internal.Duration `toml:"max_request_duration"`
+	BodyReadTimeout             internal.Duration `toml:"body_read_timeout"`
+	InjectResponseDelay         internal.Duration `toml:"inject_response_delay"`
+	TimestampRound              internal.Duration `toml:"timestamp_round"`
+	SlowWriteThreshold          internal.Duration `toml:"slow_write_threshold"`
+	HeartbeatInterval           internal.Duration `toml:"heartbeat_interval"`
+	ListenBacklog               int               `toml:"listen_backlog"`
+	TCPKeepAlivePeriod          internal.Duration `toml:"tcp_keep_alive_period"`
+	TCPNoDelay                  bool              `toml:"tcp_no_delay"`
+	MaxConnectionsPerIP         int               `toml:"max_connections_per_ip"`
+	MaxConcurrentParses         int               `toml:"max_concurrent_parses"`
+	MaxConcurrentWritesPerDB    int               `toml:"max_concurrent_writes_per_database"`
+	MaxConcurrentDecompressions int               `toml:"max_concurrent_decompressions"`
+	GlobalRateLimit             float64           `toml:"global_rate_limit"`
+	RateLimitStatusCode         int               `toml:"rate_limit_status_code"`
+	RateLimitRetryAfter         internal.Duration `toml:"rate_limit_retry_after"`
+	MaxBodySize                 internal.Size     `toml:"max_body_size"`
+	QueryMaxBodySize            internal.Size     `toml:"query_max_body_size"`
+	MaxHeaderCount              int               `toml:"max_header_count"`
+	MaxMetricsPerRequest        int               `toml:"max_metrics_per_request"`
+	MaxLineSize                 internal.Size     `toml:"max_line_size"` // deprecated in 1.14; ignored
+	MaxOversizedLineBytes       internal.Size     `toml:"max_oversized_line_bytes"`
+	MaxDecompressionRatio       int               `toml:"max_decompression_ratio"`
+	DetectGzipByMagic           bool              `toml:"detect_gzip_by_magic"`
+	GzipErrorResponses          bool              `toml:"gzip_error_responses"`
+	PointCountHeader            bool              `toml:"point_count_header"`
+	PingContentType             string            `toml:"ping_content_type"`
+	BasicUsername               string            `toml:"basic_username"`
+	BasicPassword               string            `toml:"basic_password"`
+	SkipAuthWrite               bool              `toml:"skip_auth_write"`
+	SkipAuthQuery               bool              `toml:"skip_auth_query"`
+	SkipAuthDefault             bool              `toml:"skip_auth_default"`
+	HMACSecret                  string            `toml:"hmac_secret"`
+	DatabaseTag                 string            `toml:"database_tag"`
+	DBToTag                     string            `toml:"db_to_tag"`
+	MeasurementSuffixParam      string            `toml:"measurement_suffix_param"`
+	MeasurementSuffixSeparator  string            `toml:"measurement_suffix_separator"`
+	RejectEmptyWrites           bool              `toml:"reject_empty_writes"`
+	RejectFieldlessMetrics      bool              `toml:"reject_fieldless_metrics"`
+	RequireTimestamp            bool              `toml:"require_timestamp"`
+	TolerateTrailingGarbage     bool              `toml:"tolerate_trailing_garbage"`
+	AccumulateOnReadError       bool              `toml:"accumulate_on_read_error"`
+	TrackClockSkew              bool              `toml:"track_clock_skew"`
+	AutoDetectPrecision         bool              `toml:"auto_detect_timestamp_precision"`
+	AcceptFormPrecision         bool              `toml:"accept_form_precision"`
+	ErrorVerbosity              string            `toml:"error_verbosity"`
+	EmitParseFailuresAsMetrics  bool              `toml:"emit_parse_failures_as_metrics"`
+	RedactTagsInLogs            []string          `toml:"redact_tags_in_logs"`
+	DefaultPrecision            string            `toml:"default_precision"`
+	OutputPrecision             string            `toml:"output_precision"`
+	QueryResponseStyle          string            `toml:"query_response_style"`
+	EmulateQueryStatements      bool              `toml:"emulate_query_statements"`
+
+	RequireContentLengthForCompressed bool `toml:"require_content_length_for_compressed"`
+
+	MaxTagsPerMetric   int    `toml:"max_tags_per_metric"`
+	MaxFieldsPerMetric int    `toml:"max_fields_per_metric"`
+	MetricLimitMode    string `toml:"metric_limit_mode"`
+
+	MaxDistinctTagKeysPerRequest int `toml:"max_distinct_tag_keys_per_request"`
+	MaxNewFieldsPerInterval      int `toml:"max_new_fields_per_interval"`
+
+	TagKeyPattern   string `toml:"tag_key_pattern"`
+	FieldKeyPattern string `toml:"field_key_pattern"`
+	KeyPatternMode  string `toml:"key_pattern_mode"`
+
+	TrimWhitespaceKeys      bool `toml:"trim_whitespace_keys"`
+	TrimWhitespaceTagValues bool `toml:"trim_whitespace_tag_values"`
+
+	RequireValidUTF8 bool   `toml:"require_valid_utf8"`
+	InvalidUTF8Mode  string `toml:"invalid_utf8_mode"`
+
+	TagsToFields []string `toml:"tags_to_fields"`
+	FieldsToTags []string `toml:"fields_to_tags"`
+
+	NormalizeBooleans bool `toml:"normalize_booleans"`
+
+	SanitizeMeasurementNames bool   `toml:"sanitize_measurement_names"`
+	ReplacementChar          string `toml:"replacement_char"`
+
+	SampleRatio float64 `toml:"sample_ratio"`
+
+	FlushInterval   internal.Duration `toml:"flush_interval"`
+	FlushMaxMetrics int               `toml:"flush_max_metrics"`
+
+	MaintenanceMode       bool              `toml:"maintenance_mode"`
+	MaintenanceRetryAfter internal.Duration `toml:"maintenance_retry_after"`
+
+	EnableCSVEndpoint    bool     `toml:"enable_csv_endpoint"`
+	CSVMetricName        string   `toml:"csv_metric_name"`
+	CSVHeaderRowCount    int      `toml:"csv_header_row_count"`
+	CSVDelimiter         string   `toml:"csv_delimiter"`
+	CSVColumnNames       []string `toml:"csv_column_names"`
+	CSVColumnTypes       []string `toml:"csv_column_types"`
+	CSVTagColumns        []string `toml:"csv_tag_columns"`
+	CSVFieldColumns      []string `toml:"csv_field_columns"`
+	CSVMeasurementColumn string   `toml:"csv_measurement_column"`
+	CSVTimestampColumn   string   `toml:"csv_timestamp_column"`
+	CSVTimestampFormat   string   `toml:"csv_timestamp_format"`
 
-	ReadTimeout   internal.Duration `toml:"read_timeout"`
-	WriteTimeout  internal.Duration `toml:"write_timeout"`
-	MaxBodySize   internal.Size     `toml:"max_body_size"`
-	MaxLineSize   internal.Size     `toml:"max_line_size"` // deprecated in 1.14; ignored
-	BasicUsername string            `toml:"basic_username"`
-	BasicPassword string            `toml:"basic_password"`
-	DatabaseTag   string            `toml:"database_tag"`
+	EnablePrometheusEndpoint bool `toml:"enable_prometheus_endpoint"`
+
+	EnableNDJSONEndpoint bool `toml:"enable_ndjson_endpoint"`
+
+	EnableDebugMetrics bool `toml:"enable_debug_metrics"`
+
+	EnableReadinessEndpoint  bool              `toml:"enable_readiness_endpoint"`
+	ReadinessAccumulatorWait internal.Duration `toml:"readiness_accumulator_wait"`
+
+	RootResponse RootResponse `toml:"root_response"`
+
+	HeaderTags     map[string]string `toml:"header_tags"`
+	QueryParamTags map[string]string `toml:"query_param_tags"`
+
+	ResponseHeaders map[string]string `toml:"response_headers"`
+
+	DatabaseFieldPrefix map[string]string `toml:"database_field_prefix"`
+
+	MaxTrackedDatabases int `toml:"max_tracked_databases"`
+
+	PerMeasurementStats    bool `toml:"per_measurement_stats"`
+	MaxTrackedMeasurements int  `toml:"max_tracked_measurements"`
+
+	TrackClientIPs bool `toml:"track_client_ips"`
+
+	FieldConversions map[string]FieldConversion `toml:"field_conversions"`
+
+	FieldTypes    map[string]string `toml:"field_types"`
+	FieldTypeMode string            `toml:"field_type_mode"`
 
 	timeFunc influx.TimeFunc
 
+	// parseSem holds a token for each write being parsed
+	parseSem chan struct{}
+	// number of writes being handled per database, without the databases
+	// not being written to
+	dbWritesMu sync.Mutex
+	dbWrites   map[string]int
+	// decompressSem holds a token for each gzipped body being read
+	decompressSem chan struct{}
+	// rateLimiter limits the writes accepted per second
+	rateLimiter *rateLimiter
+	// parseFailureLimiter limits the parse failure metrics added per second
+	parseFailureLimiter *rateLimiter
+	// clientIPs estimates the client IPs seen since the last gather
+	clientIPs *distinctCounter
+
+	sniCerts map[string]*tls.Certificate
+
+	// certificates revoked by tls_crl_file, keyed by issuer and serial
+	// number, and the modification time of the file they were loaded from
+	crlMu      sync.RWMutex
+	crlRevoked map[string]bool
+	crlModTime time.Time
+
+	blockedUserAgents filter.Filter
+	requiredUserAgent filter.Filter
+
+	tagKeyPattern   *regexp.Regexp
+	fieldKeyPattern *regexp.Regexp
+
+	// redactedTags matches the values of the tags in redact_tags_in_logs
+	redactedTags *regexp.Regexp
+
 	listener net.Listener
 	server   http.Server
 
+	connsMu sync.Mutex
+	conns   map[string]*trackedConn
+	ipConns map[string]int
+
+	inFlightMu sync.Mutex
+	inFlight   map[*http.Request]time.Time
+
+	// writes blocked passing metrics to the accumulator, by the time they
+	// started waiting, if the readiness endpoint is enabled
+	accWaitingMu sync.Mutex
+	accWaiting   map[*writeRequest]time.Time
+
+	dbStatsMu sync.Mutex
+	dbStats   map[string]selfstat.Stat
+
+	measurementStatsMu sync.Mutex
+	measurementStats   map[string]selfstat.Stat
+
+	// field keys seen per measurement, and the number of them first seen
+	// since the last gather
+	fieldKeysMu  sync.Mutex
+	fieldKeys    map[string]map[string]bool
+	newFieldKeys map[string]int
+
+	// clock skew of the points received since the last gather
+	skewMu    sync.Mutex
+	skewCount int64
+	skewSum   time.Duration
+	skewMin   time.Duration
+	skewMax   time.Duration
+
 	acc telegraf.Accumulator
 
+	started       time.Time
+	heartbeatDone chan struct{}
+	heartbeatWg   sync.WaitGroup
+
+	// metrics buffered for the accumulator with flush_interval
+	bufferMu  sync.Mutex
+	buffer    []telegraf.Metric
+	flushDone chan struct{}
+	flushWg   sync.WaitGroup
+
+	// time the last metric was passed to the accumulator
+	lastWriteMu sync.Mutex
+	lastWrite   time.Time
+
 	bytesRecv       selfstat.Stat
 	requestsServed  selfstat.Stat
 	writesServed    selfstat.Stat
@@ -54,9 +344,48 @@ type InfluxDBListener struct {
 	buffersCreated  selfstat.Stat
 	authFailures    selfstat.Stat
 
+	authMissingCredentials  selfstat.Stat
+	authBadUsername         selfstat.Stat
+	authBadPassword         selfstat.Stat
+	metricsOverLimit        selfstat.Stat
+	metricsOverTagKeyLimit  selfstat.Stat
+	accumulatorWait         selfstat.Stat
+	metricsSampledOut       selfstat.Stat
+	requestsAborted         selfstat.Stat
+	metricsInvalidKeys      selfstat.Stat
+	metricsInvalidUTF8      selfstat.Stat
+	metricsWrongFieldType   selfstat.Stat
+	metricsFieldless        selfstat.Stat
+	metricsTooManyNewFields selfstat.Stat
+	metricsNoTimestamp      selfstat.Stat
+	oldestInFlightAge       selfstat.Stat
+	uptime                  selfstat.Stat
+	sinceLastWrite          selfstat.Stat
+	connectionsNew          selfstat.Stat
+	connectionsReused       selfstat.Stat
+	connectionsActive       selfstat.Stat
+	connectionsIdle         selfstat.Stat
+	connectionsClosed       selfstat.Stat
+	connectionsRejected     selfstat.Stat
+	userAgentsRejected      selfstat.Stat
+	writesRateLimited       selfstat.Stat
+	tlsHandshakeErrors      selfstat.Stat
+	tlsRevokedCerts         selfstat.Stat
+	distinctClientIPs       selfstat.Stat
+	requestsGzip            selfstat.Stat
+	requestsPlaintext       selfstat.Stat
+	compressedBytes         selfstat.Stat
+	decompressedBytes       selfstat.Stat
+	decompressionsActive    selfstat.Stat
+	decompressionTime       selfstat.Stat
+	clockSkewMin            selfstat.Stat
+	clockSkewMax            selfstat.Stat
+	clockSkewAvg            selfstat.Stat
+
 	Log telegraf.Logger `toml:"-"`
 
-	mux http.ServeMux
+	mux            http.ServeMux
+	allowedMethods map[string]string
 }
 
 const sampleConfig = `
@@ -67,17 +396,284 @@ const sampleConfig = `
   read_timeout = "10s"
   ## maximum duration before timing out write of the response
   write_timeout = "10s"
+  ## maximum duration of a request before its connection is closed, even if
+  ## the client is still sending; 0 disables the limit
+  # max_request_duration = "0s"
+  ## maximum time to wait for more data while reading the body of a write,
+  ## before responding with a 408 error; unlike read_timeout it is reset
+  ## each time data arrives.  0 disables the limit
+  # body_read_timeout = "0s"
+
+  ## Delay to add before responding to writes, for testing how clients deal
+  ## with a slow server.  Not meant for production use.
+  # inject_response_delay = "0s"
+
+  ## Log a warning for writes taking longer than this, with the path, the
+  ## size of the body and the duration.  0 disables the warning.
+  # slow_write_threshold = "0s"
+
+  ## Add a telegraf_influxdb_listener_heartbeat metric with the uptime of the
+  ## listener and its request counts at this interval, so that it can be
+  ## seen to be running without any writes.  0 disables the heartbeat.
+  # heartbeat_interval = "0s"
+
+  ## Size of the queue of connections waiting to be accepted, 0 uses the
+  ## system maximum.  Only supported on Linux.
+  # listen_backlog = 0
+
+  ## Interval between TCP keep-alive probes on idle client connections, used
+  ## to detect peers that went away.  0 disables TCP keep-alive.
+  # tcp_keep_alive_period = "15s"
+
+  ## Send responses on client connections as soon as they are written
+  ## (TCP_NODELAY), which keeps the latency of small writes low.  Disabling
+  ## it lets the operating system coalesce small packets (Nagle's algorithm),
+  ## which saves packets at the cost of latency.
+  # tcp_no_delay = true
+
+  ## Maximum number of open connections from a single client IP, further
+  ## connections are closed right after being accepted.  0 means no limit.
+  # max_connections_per_ip = 0
+
+  ## Maximum number of writes parsed at the same time, further writes are
+  ## rejected with a 503 error.  0 means no limit.
+  # max_concurrent_parses = 0
+
+  ## Maximum number of writes to the same database handled at the same time,
+  ## so that a bulk import to one database cannot hold up the others.
+  ## Further writes to the database are rejected with a 503 error.  0 means
+  ## no limit.
+  # max_concurrent_writes_per_database = 0
+
+  ## Maximum number of gzipped writes decompressed at the same time, further
+  ## gzipped writes are rejected with a 503 error while plain writes are
+  ## still accepted.  0 means no limit.
+  # max_concurrent_decompressions = 0
+
+  ## Maximum number of writes accepted per second from all clients together,
+  ## with bursts of up to a second worth of writes.  Further writes are
+  ## rejected with a rate_limit_status_code error, asking the client to retry
+  ## after rate_limit_retry_after, or by default once a write would be
+  ## accepted again.  0 means no limit.
+  # global_rate_limit = 0.0
+  # rate_limit_status_code = 429
+  # rate_limit_retry_after = "0s"
 
   ## Maximum allowed HTTP request body size in bytes.
   ## 0 means to use the default of 32MiB.
   max_body_size = "32MiB"
 
+  ## Maximum allowed HTTP request body size of the /query endpoint in bytes.
+  ## 0 means to use the default of 1MiB.
+  # query_max_body_size = "1MiB"
+
+  ## Maximum number of header lines of a request, further requests are
+  ## rejected with a 431 error.  0 means no limit.
+  # max_header_count = 0
+
+  ## Maximum number of metrics of a /write or /write/ndjson request.  The
+  ## request is answered with a 400 error as soon as the limit is passed,
+  ## without reading the rest of the body; the metrics before it are still
+  ## written.  0 means no limit.
+  # max_metrics_per_request = 0
+
+  ## Maximum ratio between the decompressed and compressed size of a gzip
+  ## encoded body.  Requests expanding beyond it are aborted with a 413 error.
+  ## 0 means no limit.
+  # max_decompression_ratio = 0
+
+  ## Decompress bodies starting with the gzip magic number even without a
+  ## "Content-Encoding: gzip" header, for clients that do not set it.
+  # detect_gzip_by_magic = false
+
+  ## Reject compressed writes without a Content-Length header, such as
+  ## chunked uploads, with a 411 error, so that the size of every compressed
+  ## body is checked against max_body_size before it is read.
+  # require_content_length_for_compressed = false
+
+  ## Gzip the body of error responses to clients sending an
+  ## "Accept-Encoding: gzip" header, whether or not their request body was
+  ## compressed.  Successful writes have no body and are not affected.
+  # gzip_error_responses = false
+
+  ## Return the number of points written in the X-Points-Written header of
+  ## successful /write responses.
+  # point_count_header = false
+
+  ## Content type of the /ping responses.  When set it is used for both the
+  ## plain and the verbose response, otherwise only the verbose response has
+  ## a content type of "application/json".
+  # ping_content_type = ""
+
+  ## Maximum size of a single line in bytes.  If a line grows beyond this
+  ## size without a newline the request is aborted with a 413 error.
+  ## 0 means lines of any length are accepted.
+  # max_oversized_line_bytes = 0
+
   ## Optional tag name used to store the database. 
   ## If the write has a database in the query string then it will be kept in this tag name.
   ## This tag can be used in downstream outputs.
   ## The default value of nothing means it will be off and the database will not be recorded.
   # database_tag = ""
 
+  ## Optional tag key that always holds the database of the write, for use
+  ## with output routing such as tagpass.  Unlike database_tag, a tag of this
+  ## name sent by the client is removed when no database is supplied, so the
+  ## tag can only come from the db query parameter.
+  # db_to_tag = ""
+
+  ## Optional query parameter whose value is appended to the measurement
+  ## names of a write, after measurement_suffix_separator, such as an
+  ## environment passed as "?env=staging".  Writes without the parameter
+  ## keep their measurement names.
+  # measurement_suffix_param = ""
+  # measurement_suffix_separator = "_"
+
+  ## Reject writes with an empty body with a 400 error instead of accepting
+  ## them as a successful write of no metrics.
+  # reject_empty_writes = false
+
+  ## Drop metrics left without any field, for instance by csv_field_columns
+  ## or key_pattern_mode, and report the write as partial.
+  # reject_fieldless_metrics = false
+
+  ## Drop points without a timestamp, instead of giving them the time they
+  ## are received, and report the write as partial.
+  # require_timestamp = false
+
+  ## Ignore lines without printable characters, such as null padding,
+  ## following the last valid point of a write instead of reporting a partial
+  ## write.  Malformed points are still reported.
+  # tolerate_trailing_garbage = false
+
+  ## When reading the body of a write fails midway, such as when the client
+  ## disconnects, keep the points of the complete lines read so far and
+  ## report a partial write, instead of rejecting the write.
+  # accumulate_on_read_error = false
+
+  ## Track the difference between the server time and the timestamps of the
+  ## written points, reported as the clock_skew_min_ns, clock_skew_max_ns and
+  ## clock_skew_avg_ns internal metrics.  Helps finding clients with a wrong
+  ## clock.
+  # track_clock_skew = false
+
+  ## Guess the precision of timestamps that are implausible, before 1971 or
+  ## after 2200, in the precision of the write.  The first of s, ms, us and
+  ## ns giving a plausible time is used instead.
+  # auto_detect_timestamp_precision = false
+
+  ## Truncate the timestamps of the metrics to a multiple of this duration,
+  ## such as "1s" to remove sub-second jitter.  0 keeps them as they are.
+  # timestamp_round = "0s"
+
+  ## Maximum number of tags and fields allowed per metric, 0 means unlimited.
+  ## Metrics over a limit are dropped and the write is reported as partial,
+  ## or, if metric_limit_mode is "trim", the extra tags and fields are removed.
+  # max_tags_per_metric = 0
+  # max_fields_per_metric = 0
+  # metric_limit_mode = "drop"
+
+  ## Maximum number of distinct tag keys across all metrics of a request,
+  ## 0 means unlimited.  Metrics adding tag keys over the limit are dropped
+  ## and the write is reported as partial.
+  # max_distinct_tag_keys_per_request = 0
+
+  ## Maximum number of field keys not seen before that the metrics of a
+  ## measurement may introduce per collection interval, 0 means unlimited.
+  ## Metrics introducing field keys over the limit are dropped and the write
+  ## is reported as partial.  The field keys of up to max_tracked_measurements
+  ## measurements are remembered; further measurements are not limited.
+  # max_new_fields_per_interval = 0
+
+  ## Regular expressions tag and field keys must match.  Metrics with other
+  ## keys are dropped and the write is reported as partial, or, if
+  ## key_pattern_mode is "trim", the offending tags and fields are removed.
+  # tag_key_pattern = "^[a-z0-9_]+$"
+  # field_key_pattern = "^[a-z0-9_]+$"
+  # key_pattern_mode = "drop"
+
+  ## Remove leading and trailing whitespace from tag and field keys, and
+  ## optionally from tag values.  If a trimmed key collides with an existing
+  ## one, the value that comes last replaces the other.
+  # trim_whitespace_keys = false
+  # trim_whitespace_tag_values = false
+
+  ## Check that measurement names, tag and field keys, tag values and string
+  ## field values are valid UTF-8.  Invalid metrics are dropped and the write
+  ## is reported as partial, or, if invalid_utf8_mode is "sanitize", the
+  ## invalid bytes are replaced with the U+FFFD replacement character.
+  # require_valid_utf8 = false
+  # invalid_utf8_mode = "drop"
+
+  ## How to handle field values not of the type set for them in field_types:
+  ## "coerce" converts them when that loses no information and drops the
+  ## metric otherwise, "drop" always drops the metric.  Dropped metrics are
+  ## reported as a partial write.
+  # field_type_mode = "coerce"
+
+  ## Tag keys to turn into fields, and field keys to turn into tags.  Tag
+  ## values become integer or float fields if they are numbers and string
+  ## fields otherwise.  Existing fields or tags of the same key are replaced.
+  # tags_to_fields = []
+  # fields_to_tags = []
+
+  ## Turn string fields spelling a boolean into boolean fields.  "t", "true",
+  ## "y", "yes", "on" and "1" become true, "f", "false", "n", "no", "off" and
+  ## "0" become false, regardless of case.  Integer fields are left alone.
+  # normalize_booleans = false
+
+  ## Replace characters other than ASCII letters, digits, "_", "-" and "."
+  ## in measurement names with replacement_char.
+  # sanitize_measurement_names = false
+  # replacement_char = "_"
+
+  ## Fraction of metrics to keep, between 0 and 1.  Metrics are dropped at
+  ## random so that on average only this share is written.  The default of
+  ## 1.0 keeps every metric.
+  # sample_ratio = 1.0
+
+  ## Buffer the metrics of all writes and pass them to the accumulator every
+  ## flush_interval, or as soon as flush_max_metrics metrics are buffered.
+  ## This reduces the work per write under spiky load, at the cost of the
+  ## metrics reaching the outputs later.  Buffered metrics are passed on when
+  ## the listener stops.  0 passes the metrics on as they are written.
+  # flush_interval = "0s"
+  # flush_max_metrics = 1000
+
+  ## Amount of detail about malformed writes returned to the client, either
+  ## "full" to include the parser error or "minimal" for a generic message.
+  ## The full error is always logged at debug level.
+  # error_verbosity = "full"
+
+  ## Add a telegraf_influxdb_listener_parse_failure metric for each line of
+  ## line protocol that fails to parse, tagged with the client IP and the
+  ## database, with the parser error quoting the start of the line and the
+  ## position of the error as fields.
+  ## At most 10 such metrics are added per second, further failures are only
+  ## reported to the client.
+  # emit_parse_failures_as_metrics = false
+
+  ## Tag keys whose values are replaced with "***" in the line protocol quoted
+  ## by logged parse errors and by parse failure metrics, for tags that may
+  ## hold personal data.
+  # redact_tags_in_logs = []
+
+  ## Body returned by the /query endpoint, either "empty" for an empty list of
+  ## results or "compat" for a single empty result with a statement_id, as
+  ## expected by some InfluxDB clients.
+  # query_response_style = "empty"
+
+  ## Answer CREATE DATABASE and SHOW DATABASES statements sent to /query as
+  ## InfluxDB would, with a result for each statement of the query.  SHOW
+  ## DATABASES returns no databases.
+  # emulate_query_statements = false
+
+  ## Reject all writes with a 503 error while keeping the listener up, for
+  ## instance during planned maintenance.  Clients are asked to retry after
+  ## maintenance_retry_after.  Pings are still answered.
+  # maintenance_mode = false
+  # maintenance_retry_after = "60s"
+
   ## Set one or more allowed client CA certificate file names to
   ## enable mutually authenticated TLS connections
   tls_allowed_cacerts = ["/etc/telegraf/clientca.pem"]
@@ -86,10 +682,183 @@ const sampleConfig = `
   tls_cert = "/etc/telegraf/cert.pem"
   tls_key = "/etc/telegraf/key.pem"
 
+  ## Optional certificate revocation list, in PEM or DER format.  Client
+  ## certificates it revokes are rejected during the TLS handshake.  The
+  ## file is reloaded every collection interval when it has changed.
+  # tls_crl_file = "/etc/telegraf/clientca.crl"
+
+  ## Reject any request not received over TLS with a 403 error.
+  # require_tls = false
+
   ## Optional username and password to accept for HTTP basic authentication.
   ## You probably want to make sure you have TLS configured above for this.
   # basic_username = "foobar"
   # basic_password = "barfoo"
+
+  ## Serve the write endpoints, the /query endpoint or any other path without
+  ## basic authentication.  /ping never requires it.
+  # skip_auth_write = false
+  # skip_auth_query = false
+  # skip_auth_default = false
+
+  ## Reject writes with another method than POST with a 405 error.
+  # strict_methods = false
+
+  ## Reject writes with a query parameter other than db, rp, precision,
+  ## consistency, measurement_suffix_param and the keys of query_param_tags
+  ## with a 400 error.
+  # strict_query_params = false
+
+  ## Reject writes from clients whose User-Agent matches one of these globs
+  ## with a 403 error asking them to upgrade, such as versions with a known
+  ## bug.  If required_user_agent is set, writes from clients whose
+  ## User-Agent does not match it are rejected as well.
+  # blocked_user_agents = []
+  # required_user_agent = ""
+
+  ## Optional secret used to verify writes.  When set, writes must have an
+  ## X-Signature header holding the hex encoded HMAC-SHA256 of the
+  ## uncompressed body, otherwise they are rejected with a 401 error.  The
+  ## whole body is read into memory before it is parsed, bodies larger than
+  ## max_body_size once decompressed are rejected with a 413 error.
+  # hmac_secret = ""
+
+  ## Parse URL encoded writes as a form, reading the points from the "data"
+  ## field and the precision from the "precision" field unless it is given in
+  ## the query string.  URL encoded bodies without a "data" field, such as
+  ## those curl sends for --data-binary unless a Content-Type is set, are
+  ## parsed as line protocol.
+  # accept_form_precision = false
+
+  ## Precision of the timestamps of writes without a precision parameter.
+  ## Defaults to nanoseconds.
+  # default_precision = "ns"
+
+  ## Precision the outputs keep timestamps at, such as "ms" or "s".  The
+  ## timestamps of all metrics are truncated to it, whatever the precision
+  ## of the write.  Defaults to nanoseconds, keeping them as they are.
+  # output_precision = "ns"
+
+  ## Enable the /write/csv endpoint, which accepts metrics in CSV format.
+  # enable_csv_endpoint = false
+
+  ## Measurement name used for CSV rows when no measurement column is set.
+  # csv_metric_name = "influxdb_listener"
+
+  ## Number of header rows in the CSV body; when csv_column_names is empty
+  ## the column names are taken from the header.
+  # csv_header_row_count = 1
+
+  ## Column delimiter, defaults to ",".
+  # csv_delimiter = ","
+
+  ## Column names and types, used when the body has no header.  Types may be
+  ## "int", "float", "bool" or "string".
+  # csv_column_names = []
+  # csv_column_types = []
+
+  ## Columns to add as tags.  Of the remaining columns, only those listed in
+  ## csv_field_columns are kept as fields; if empty all are kept.
+  # csv_tag_columns = []
+  # csv_field_columns = []
+
+  ## Column holding the measurement name.
+  # csv_measurement_column = ""
+
+  ## Column holding the timestamp and its format, either "unix", "unix_ms",
+  ## "unix_us", "unix_ns" or a Go time layout.
+  # csv_timestamp_column = ""
+  # csv_timestamp_format = ""
+
+  ## Enable the /write/prometheus endpoint, which accepts metrics in the
+  ## Prometheus text exposition format.  Histogram buckets and summary
+  ## quantiles become fields of a single metric.
+  # enable_prometheus_endpoint = false
+
+  ## Enable the /write/ndjson endpoint, which accepts newline delimited JSON
+  ## objects with a name, tags, fields and an optional timestamp.  The
+  ## precision of the timestamps is set as for /write.
+  # enable_ndjson_endpoint = false
+
+  ## Enable the /debug/metrics endpoint, which returns the internal metrics
+  ## of this listener in InfluxDB Line Protocol.
+  # enable_debug_metrics = false
+
+  ## Enable the /health and /ready endpoints.  /health always returns 200
+  ## while the listener is running.  /ready returns 503 in maintenance mode
+  ## or while a write has been waiting on the accumulator for longer than
+  ## readiness_accumulator_wait, as it does when outputs fall behind.
+  # enable_readiness_endpoint = false
+  # readiness_accumulator_wait = "1s"
+
+  ## Count the writes to each database in the database_writes internal
+  ## metric, tagged by database, for up to this many databases.  Writes to
+  ## further databases are counted under the "other" database.  0 disables
+  ## the per database count.
+  # max_tracked_databases = 0
+
+  ## Count the metrics written to each measurement in the measurement_writes
+  ## internal metric, tagged by measurement, for up to
+  ## max_tracked_measurements measurements.  Metrics of further measurements
+  ## are counted under the "other" measurement.
+  # per_measurement_stats = false
+  # max_tracked_measurements = 100
+
+  ## Estimate the number of distinct client IPs sending requests in the
+  ## distinct_client_ips internal metric.  The estimate covers the requests
+  ## since the previous collection interval and starts over at each one; it
+  ## uses a fixed amount of memory and is accurate to a few percent for up
+  ## to tens of thousands of clients.
+  # track_client_ips = false
+
+  ## Optional mapping of request headers to tag keys.  When a request has
+  ## one of these headers, its value is added as a tag to every metric.
+  # [inputs.influxdb_listener.header_tags]
+  #   X-Tenant = "tenant"
+  #   X-Environment = "environment"
+
+  ## Optional mapping of query parameters to tag keys.  When a write has one
+  ## of these parameters, its value is added as a tag to every metric.
+  # [inputs.influxdb_listener.query_param_tags]
+  #   rp = "retention_policy"
+  #   consistency = "consistency"
+
+  ## Optional headers to add to every response, such as for a proxy or CDN
+  ## in front of the listener.  Headers set by the endpoints themselves, such
+  ## as Content-Type, take precedence.
+  # [inputs.influxdb_listener.response_headers]
+  #   Cache-Control = "no-store"
+
+  ## Optional prefixes to add to the field keys of the metrics written to a
+  ## database.  Metrics of other databases are left as they are.
+  # [inputs.influxdb_listener.database_field_prefix]
+  #   telegraf = "telegraf_"
+
+  ## Optional linear conversions of field values, keyed by measurement and
+  ## field name joined with a dot.  Numeric values are replaced by
+  ## value * scale + offset, stored as a float; a scale of 0 is taken as 1.
+  ## Other field types are left as they are.
+  # [inputs.influxdb_listener.field_conversions."weather.temperature"]
+  #   scale = 0.5555555555555556
+  #   offset = -17.77777777777778
+
+  ## Optional types of fields, keyed by measurement and field name joined
+  ## with a dot.  The type is one of "integer", "float", "string" or
+  ## "boolean"; see field_type_mode.
+  # [inputs.influxdb_listener.field_types]
+  #   "weather.humidity" = "integer"
+
+  ## Optional certificates to serve by server name, instead of tls_cert, to
+  ## clients using SNI.  Requires tls_cert and tls_key to be set.
+  # [inputs.influxdb_listener.tls_sni_certs."metrics.example.com"]
+  #   tls_cert = "/etc/telegraf/metrics.example.com.pem"
+  #   tls_key = "/etc/telegraf/metrics.example.com.key"
+
+  ## Optional response to requests for the root path "/", such as for health
+  ## checks.  Other unknown paths are still answered with a 404 error.
+  # [inputs.influxdb_listener.root_response]
+  #   status = 200
+  #   body = "OK"
 `
 
 func (h *InfluxDBListener) SampleConfig() string {
@@ -101,20 +870,132 @@ func (h *InfluxDBListener) Description() string {
 }
 
 func (h *InfluxDBListener) Gather(_ telegraf.Accumulator) error {
+	now := time.Now()
+	var oldest time.Duration
+	h.inFlightMu.Lock()
+	for _, start := range h.inFlight {
+		if age := now.Sub(start); age > oldest {
+			oldest = age
+		}
+	}
+	h.inFlightMu.Unlock()
+	h.oldestInFlightAge.Set(oldest.Nanoseconds())
+
+	// Until the first write, the time since the last write is the uptime.
+	h.lastWriteMu.Lock()
+	lastWrite := h.lastWrite
+	h.lastWriteMu.Unlock()
+	if lastWrite.IsZero() {
+		lastWrite = h.started
+	}
+	h.uptime.Set(int64(now.Sub(h.started).Seconds()))
+	h.sinceLastWrite.Set(int64(now.Sub(lastWrite).Seconds()))
+
+	h.skewMu.Lock()
+	if h.skewCount > 0 {
+		h.clockSkewMin.Set(h.skewMin.Nanoseconds())
+		h.clockSkewMax.Set(h.skewMax.Nanoseconds())
+		h.clockSkewAvg.Set(h.skewSum.Nanoseconds() / h.skewCount)
+		h.skewCount = 0
+		h.skewSum = 0
+	}
+	h.skewMu.Unlock()
+
+	h.fieldKeysMu.Lock()
+	h.newFieldKeys = make(map[string]int)
+	h.fieldKeysMu.Unlock()
+
+	if h.clientIPs != nil {
+		h.distinctClientIPs.Set(h.clientIPs.reset())
+	}
+
+	if h.TLSCRLFile != "" {
+		if err := h.loadCRL(); err != nil {
+			h.Log.Errorf("Error reloading tls_crl_file, keeping the previous list: %v", err)
+		}
+	}
 	return nil
 }
 
+// recordClockSkew adds the difference between the server time and the
+// timestamp of a point to the clock skew statistics.
+func (h *InfluxDBListener) recordClockSkew(t time.Time) {
+	skew := h.timeFunc().Sub(t)
+
+	h.skewMu.Lock()
+	defer h.skewMu.Unlock()
+	if h.skewCount == 0 || skew < h.skewMin {
+		h.skewMin = skew
+	}
+	if h.skewCount == 0 || skew > h.skewMax {
+		h.skewMax = skew
+	}
+	h.skewSum += skew
+	h.skewCount++
+}
+
 func (h *InfluxDBListener) routes() {
-	authHandler := internal.AuthHandler(h.BasicUsername, h.BasicPassword, "influxdb",
-		func(_ http.ResponseWriter) {
-			h.authFailures.Incr(1)
-		},
-	)
+	h.allowedMethods = make(map[string]string)
+	h.handle("/write", "POST", h.writeRoute(h.handleWrite()))
+	if h.EnableCSVEndpoint {
+		h.handle("/write/csv", "POST", h.writeRoute(h.handleWriteCSV()))
+	}
+	if h.EnablePrometheusEndpoint {
+		h.handle("/write/prometheus", "POST", h.writeRoute(h.handleWritePrometheus()))
+	}
+	if h.EnableNDJSONEndpoint {
+		h.handle("/write/ndjson", "POST", h.writeRoute(h.handleWriteNDJSON()))
+	}
+	if h.EnableReadinessEndpoint {
+		h.handle("/health", "GET, HEAD", h.handleHealth())
+		h.handle("/ready", "GET, HEAD", h.handleReady())
+	}
+	if h.EnableDebugMetrics {
+		h.handle("/debug/metrics", "GET", h.authHandler(h.handleDebugMetrics()))
+	}
+	h.handle("/query", "GET, POST", h.withAuth(!h.SkipAuthQuery, h.handleQuery()))
+	h.handle("/ping", "GET, HEAD", h.handlePing())
+	h.mux.Handle("/", h.withAuth(!h.SkipAuthDefault, h.handleDefault()))
+}
+
+// writeRoute wraps the handler of a write endpoint in the checks shared by
+// all writes.
+func (h *InfluxDBListener) writeRoute(handler http.HandlerFunc) http.Handler {
+	return h.withAuth(!h.SkipAuthWrite, h.postOnly(h.strictParams(h.userAgentHandler(h.maintenanceHandler(h.contentLengthHandler(h.rateLimitHandler(h.parseLimitHandler(h.dbLimitHandler(handler)))))))))
+}
+
+// handle registers the handler of a route along with the methods it
+// supports, which are listed in response to OPTIONS requests.
+func (h *InfluxDBListener) handle(pattern string, methods string, handler http.Handler) {
+	h.allowedMethods[pattern] = methods + ", OPTIONS"
+	h.mux.Handle(pattern, handler)
+}
+
+// trimTrailingSlash routes requests for a route with a trailing slash, such
+// as "/write/", to the route itself.  Registering both patterns with the mux
+// would also route any path below "/write/" to the write handler.
+func (h *InfluxDBListener) trimTrailingSlash(req *http.Request) {
+	path := strings.TrimSuffix(req.URL.Path, "/")
+	if path == req.URL.Path {
+		return
+	}
+	if _, ok := h.allowedMethods[path]; !ok {
+		return
+	}
+	req.URL.Path = path
+	req.URL.RawPath = strings.TrimSuffix(req.URL.RawPath, "/")
+}
 
-	h.mux.Handle("/write", authHandler(h.handleWrite()))
-	h.mux.Handle("/query", authHandler(h.handleQuery()))
-	h.mux.Handle("/ping", h.handlePing())
-	h.mux.Handle("/", authHandler(h.handleDefault()))
+// handleOptions responds to OPTIONS requests for a route with the methods it
+// supports.  Requests for "*" are answered by net/http itself.
+func (h *InfluxDBListener) handleOptions(res http.ResponseWriter, req *http.Request) bool {
+	methods, ok := h.allowedMethods[req.URL.Path]
+	if !ok {
+		return false
+	}
+	res.Header().Set("Allow", methods)
+	res.WriteHeader(http.StatusNoContent)
+	return true
 }
 
 func (h *InfluxDBListener) Init() error {
@@ -130,217 +1011,2951 @@ func (h *InfluxDBListener) Init() error {
 	h.notFoundsServed = selfstat.Register("influxdb_listener", "not_founds_served", tags)
 	h.buffersCreated = selfstat.Register("influxdb_listener", "buffers_created", tags)
 	h.authFailures = selfstat.Register("influxdb_listener", "auth_failures", tags)
+	h.authMissingCredentials = selfstat.Register("influxdb_listener", "auth_failures_missing_credentials", tags)
+	h.authBadUsername = selfstat.Register("influxdb_listener", "auth_failures_bad_username", tags)
+	h.authBadPassword = selfstat.Register("influxdb_listener", "auth_failures_bad_password", tags)
+	h.metricsOverLimit = selfstat.Register("influxdb_listener", "metrics_over_limit", tags)
+	h.metricsOverTagKeyLimit = selfstat.Register("influxdb_listener", "metrics_over_tag_key_limit", tags)
+	h.accumulatorWait = selfstat.Register("influxdb_listener", "accumulator_wait_ns", tags)
+	h.metricsSampledOut = selfstat.Register("influxdb_listener", "metrics_sampled_out", tags)
+	h.requestsAborted = selfstat.Register("influxdb_listener", "requests_aborted", tags)
+	h.metricsInvalidKeys = selfstat.Register("influxdb_listener", "metrics_invalid_keys", tags)
+	h.metricsInvalidUTF8 = selfstat.Register("influxdb_listener", "metrics_invalid_utf8", tags)
+	h.metricsWrongFieldType = selfstat.Register("influxdb_listener", "metrics_wrong_field_type", tags)
+	h.metricsFieldless = selfstat.Register("influxdb_listener", "metrics_fieldless", tags)
+	h.metricsTooManyNewFields = selfstat.Register("influxdb_listener", "metrics_too_many_new_fields", tags)
+	h.metricsNoTimestamp = selfstat.Register("influxdb_listener", "metrics_without_timestamp", tags)
+	h.oldestInFlightAge = selfstat.Register("influxdb_listener", "oldest_in_flight_request_age_ns", tags)
+	h.uptime = selfstat.Register("influxdb_listener", "uptime_seconds", tags)
+	h.sinceLastWrite = selfstat.Register("influxdb_listener", "seconds_since_last_write", tags)
+	h.connectionsNew = selfstat.Register("influxdb_listener", "connections_new", tags)
+	h.connectionsReused = selfstat.Register("influxdb_listener", "connections_reused", tags)
+	h.connectionsActive = selfstat.Register("influxdb_listener", "connections_active", tags)
+	h.connectionsIdle = selfstat.Register("influxdb_listener", "connections_idle", tags)
+	h.connectionsClosed = selfstat.Register("influxdb_listener", "connections_closed", tags)
+	h.connectionsRejected = selfstat.Register("influxdb_listener", "connections_rejected", tags)
+	h.userAgentsRejected = selfstat.Register("influxdb_listener", "user_agents_rejected", tags)
+	h.writesRateLimited = selfstat.Register("influxdb_listener", "writes_rate_limited", tags)
+	h.tlsHandshakeErrors = selfstat.Register("influxdb_listener", "tls_handshake_errors", tags)
+	h.tlsRevokedCerts = selfstat.Register("influxdb_listener", "tls_revoked_certificates", tags)
+	h.distinctClientIPs = selfstat.Register("influxdb_listener", "distinct_client_ips", tags)
+	h.requestsGzip = selfstat.Register("influxdb_listener", "requests_gzip", tags)
+	h.requestsPlaintext = selfstat.Register("influxdb_listener", "requests_plaintext", tags)
+	h.compressedBytes = selfstat.Register("influxdb_listener", "compressed_bytes", tags)
+	h.decompressedBytes = selfstat.Register("influxdb_listener", "decompressed_bytes", tags)
+	h.decompressionsActive = selfstat.Register("influxdb_listener", "decompressions_active", tags)
+	h.decompressionTime = selfstat.Register("influxdb_listener", "decompression_time_ns", tags)
+	h.clockSkewMin = selfstat.Register("influxdb_listener", "clock_skew_min_ns", tags)
+	h.clockSkewMax = selfstat.Register("influxdb_listener", "clock_skew_max_ns", tags)
+	h.clockSkewAvg = selfstat.Register("influxdb_listener", "clock_skew_avg_ns", tags)
+	h.inFlight = make(map[*http.Request]time.Time)
+	h.accWaiting = make(map[*writeRequest]time.Time)
+	h.dbStats = make(map[string]selfstat.Stat)
+	h.measurementStats = make(map[string]selfstat.Stat)
+	h.dbWrites = make(map[string]int)
+	h.fieldKeys = make(map[string]map[string]bool)
+	h.newFieldKeys = make(map[string]int)
+	if h.TrackClientIPs {
+		h.clientIPs = newDistinctCounter()
+	}
 	h.routes()
 
 	if h.MaxBodySize.Size == 0 {
 		h.MaxBodySize.Size = defaultMaxBodySize
 	}
+	if h.QueryMaxBodySize.Size == 0 {
+		h.QueryMaxBodySize.Size = defaultQueryMaxBodySize
+	}
 
-	if h.MaxLineSize.Size != 0 {
-		h.Log.Warnf("Use of deprecated configuration: 'max_line_size'; parser now handles lines of unlimited length and option is ignored")
+	switch h.MetricLimitMode {
+	case "":
+		h.MetricLimitMode = "drop"
+	case "drop", "trim":
+	default:
+		return fmt.Errorf("invalid metric_limit_mode %q", h.MetricLimitMode)
 	}
 
-	if h.ReadTimeout.Duration < time.Second {
-		h.ReadTimeout.Duration = time.Second * 10
+	if !validPrecision(h.DefaultPrecision) {
+		return fmt.Errorf("invalid default_precision %q", h.DefaultPrecision)
 	}
-	if h.WriteTimeout.Duration < time.Second {
-		h.WriteTimeout.Duration = time.Second * 10
+	if !validPrecision(h.OutputPrecision) {
+		return fmt.Errorf("invalid output_precision %q", h.OutputPrecision)
 	}
 
-	return nil
-}
-
-// Start starts the InfluxDB listener service.
-func (h *InfluxDBListener) Start(acc telegraf.Accumulator) error {
-	h.acc = acc
+	switch h.ErrorVerbosity {
+	case "":
+		h.ErrorVerbosity = "full"
+	case "full", "minimal":
+	default:
+		return fmt.Errorf("invalid error_verbosity %q", h.ErrorVerbosity)
+	}
 
-	tlsConf, err := h.ServerConfig.TLSConfig()
-	if err != nil {
-		return err
+	switch h.InvalidUTF8Mode {
+	case "":
+		h.InvalidUTF8Mode = "drop"
+	case "drop", "sanitize":
+	default:
+		return fmt.Errorf("invalid invalid_utf8_mode %q", h.InvalidUTF8Mode)
 	}
 
-	h.server = http.Server{
-		Addr:         h.ServiceAddress,
-		Handler:      h,
-		ReadTimeout:  h.ReadTimeout.Duration,
-		WriteTimeout: h.WriteTimeout.Duration,
-		TLSConfig:    tlsConf,
+	switch h.FieldTypeMode {
+	case "":
+		h.FieldTypeMode = "coerce"
+	case "coerce", "drop":
+	default:
+		return fmt.Errorf("invalid field_type_mode %q", h.FieldTypeMode)
+	}
+	for key, typ := range h.FieldTypes {
+		switch typ {
+		case "integer", "float", "string", "boolean":
+		default:
+			return fmt.Errorf("invalid field type %q for %q", typ, key)
+		}
 	}
 
-	var listener net.Listener
-	if tlsConf != nil {
-		listener, err = tls.Listen("tcp", h.ServiceAddress, tlsConf)
+	switch h.KeyPatternMode {
+	case "":
+		h.KeyPatternMode = "drop"
+	case "drop", "trim":
+	default:
+		return fmt.Errorf("invalid key_pattern_mode %q", h.KeyPatternMode)
+	}
+	if len(h.RedactTagsInLogs) > 0 {
+		keys := make([]string, 0, len(h.RedactTagsInLogs))
+		for _, key := range h.RedactTagsInLogs {
+			keys = append(keys, regexp.QuoteMeta(key))
+		}
+		// A tag value runs up to the next unescaped comma or space, or the
+		// end of the quoted line in a parse error, where the backslash of an
+		// escaped character is itself escaped.
+		h.redactedTags = regexp.MustCompile(`(,(?:` + strings.Join(keys, "|") + `)=)(?:\\\\.|\\.|[^,\s\\"])*`)
+	}
+	for key, value := range h.ResponseHeaders {
+		if !validHeaderName(key) {
+			return fmt.Errorf("invalid response_headers name %q", key)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("invalid response_headers value %q of %q", value, key)
+		}
+	}
+	if h.TagKeyPattern != "" {
+		re, err := regexp.Compile(h.TagKeyPattern)
 		if err != nil {
-			return err
+			return fmt.Errorf("invalid tag_key_pattern: %v", err)
 		}
-	} else {
-		listener, err = net.Listen("tcp", h.ServiceAddress)
+		h.tagKeyPattern = re
+	}
+	if h.FieldKeyPattern != "" {
+		re, err := regexp.Compile(h.FieldKeyPattern)
 		if err != nil {
-			return err
+			return fmt.Errorf("invalid field_key_pattern: %v", err)
 		}
+		h.fieldKeyPattern = re
 	}
-	h.listener = listener
-	h.port = listener.Addr().(*net.TCPAddr).Port
 
-	go func() {
-		err = h.server.Serve(h.listener)
-		if err != http.ErrServerClosed {
-			h.Log.Infof("Error serving HTTP on %s", h.ServiceAddress)
+	blocked, err := filter.Compile(h.BlockedUserAgents)
+	if err != nil {
+		return fmt.Errorf("invalid blocked_user_agents: %v", err)
+	}
+	h.blockedUserAgents = blocked
+	if h.RequiredUserAgent != "" {
+		required, err := filter.Compile([]string{h.RequiredUserAgent})
+		if err != nil {
+			return fmt.Errorf("invalid required_user_agent: %v", err)
 		}
-	}()
+		h.requiredUserAgent = required
+	}
 
-	h.Log.Infof("Started HTTP listener service on %s", h.ServiceAddress)
+	if h.RootResponse.Status != 0 && (h.RootResponse.Status < 100 || h.RootResponse.Status > 999) {
+		return fmt.Errorf("invalid root_response status %d", h.RootResponse.Status)
+	}
 
-	return nil
-}
+	switch h.QueryResponseStyle {
+	case "":
+		h.QueryResponseStyle = "empty"
+	case "empty", "compat":
+	default:
+		return fmt.Errorf("invalid query_response_style %q", h.QueryResponseStyle)
+	}
 
-// Stop cleans up all resources
-func (h *InfluxDBListener) Stop() {
-	err := h.server.Shutdown(context.Background())
-	if err != nil {
-		h.Log.Infof("Error shutting down HTTP server: %v", err.Error())
+	if h.MeasurementSuffixSeparator == "" {
+		h.MeasurementSuffixSeparator = "_"
+	}
+	if h.FlushMaxMetrics <= 0 {
+		h.FlushMaxMetrics = 1000
 	}
-}
 
-func (h *InfluxDBListener) ServeHTTP(res http.ResponseWriter, req *http.Request) {
-	h.requestsRecv.Incr(1)
-	h.mux.ServeHTTP(res, req)
-	h.requestsServed.Incr(1)
-}
+	if h.ReadinessAccumulatorWait.Duration <= 0 {
+		h.ReadinessAccumulatorWait.Duration = time.Second
+	}
 
-func (h *InfluxDBListener) handleQuery() http.HandlerFunc {
-	return func(res http.ResponseWriter, req *http.Request) {
-		defer h.queriesServed.Incr(1)
-		// Deliver a dummy response to the query endpoint, as some InfluxDB
-		// clients test endpoint availability with a query
-		res.Header().Set("Content-Type", "application/json")
-		res.Header().Set("X-Influxdb-Version", "1.0")
-		res.WriteHeader(http.StatusOK)
-		res.Write([]byte("{\"results\":[]}"))
+	if h.MaxTrackedMeasurements <= 0 {
+		h.MaxTrackedMeasurements = 100
 	}
-}
 
-func (h *InfluxDBListener) handlePing() http.HandlerFunc {
+	for key, conv := range h.FieldConversions {
+		if conv.Scale == 0 {
+			conv.Scale = 1
+			h.FieldConversions[key] = conv
+		}
+	}
+
+	if h.ReplacementChar == "" {
+		h.ReplacementChar = "_"
+	}
+
+	if h.SampleRatio == 0 {
+		h.SampleRatio = 1.0
+	}
+	if h.SampleRatio < 0 || h.SampleRatio > 1 {
+		return fmt.Errorf("sample_ratio must be between 0 and 1, got %v", h.SampleRatio)
+	}
+
+	if h.MaxConcurrentParses > 0 {
+		h.parseSem = make(chan struct{}, h.MaxConcurrentParses)
+	}
+
+	if h.GlobalRateLimit < 0 {
+		return fmt.Errorf("invalid global_rate_limit %v", h.GlobalRateLimit)
+	}
+	switch {
+	case h.RateLimitStatusCode == 0:
+		h.RateLimitStatusCode = http.StatusTooManyRequests
+	case h.RateLimitStatusCode < 400 || h.RateLimitStatusCode > 599:
+		return fmt.Errorf("invalid rate_limit_status_code %d", h.RateLimitStatusCode)
+	}
+	if h.GlobalRateLimit > 0 {
+		h.rateLimiter = newRateLimiter(h.GlobalRateLimit, time.Now())
+	}
+	if h.EmitParseFailuresAsMetrics {
+		h.parseFailureLimiter = newRateLimiter(parseFailureMetricRate, time.Now())
+	}
+	if h.MaxConcurrentDecompressions > 0 {
+		h.decompressSem = make(chan struct{}, h.MaxConcurrentDecompressions)
+	}
+	if h.MaintenanceRetryAfter.Duration == 0 {
+		h.MaintenanceRetryAfter.Duration = 60 * time.Second
+	}
+
+	if h.CSVMetricName == "" {
+		h.CSVMetricName = "influxdb_listener"
+	}
+
+	if h.MaxLineSize.Size != 0 {
+		h.Log.Warnf("Use of deprecated configuration: 'max_line_size'; parser now handles lines of unlimited length and option is ignored")
+	}
+
+	if h.RequireTLS && h.TLSCert == "" {
+		h.Log.Warnf("Option 'require_tls' is set but TLS is not configured; all requests will be rejected")
+	}
+
+	if len(h.TLSSNICerts) > 0 && h.TLSCert == "" {
+		h.Log.Warnf("Option 'tls_sni_certs' is set but TLS is not configured; it is ignored")
+	}
+	h.sniCerts = make(map[string]*tls.Certificate)
+	for name, sni := range h.TLSSNICerts {
+		cert, err := tls.LoadX509KeyPair(sni.TLSCert, sni.TLSKey)
+		if err != nil {
+			return fmt.Errorf("could not load certificate for server name %q: %v", name, err)
+		}
+		h.sniCerts[strings.ToLower(name)] = &cert
+	}
+
+	if h.TLSCRLFile != "" {
+		if h.TLSCert == "" {
+			h.Log.Warnf("Option 'tls_crl_file' is set but TLS is not configured; it is ignored")
+		}
+		if err := h.loadCRL(); err != nil {
+			return fmt.Errorf("could not load tls_crl_file: %v", err)
+		}
+	}
+
+	if h.ReadTimeout.Duration < time.Second {
+		h.ReadTimeout.Duration = time.Second * 10
+	}
+	if h.WriteTimeout.Duration < time.Second {
+		h.WriteTimeout.Duration = time.Second * 10
+	}
+
+	return nil
+}
+
+// Start starts the InfluxDB listener service.
+func (h *InfluxDBListener) Start(acc telegraf.Accumulator) error {
+	h.acc = acc
+
+	tlsConf, err := h.ServerConfig.TLSConfig()
+	if err != nil {
+		return err
+	}
+	if tlsConf != nil && len(h.sniCerts) > 0 {
+		tlsConf.GetCertificate = h.getCertificate
+	}
+	if tlsConf != nil && h.TLSCRLFile != "" {
+		tlsConf.VerifyPeerCertificate = h.verifyPeerCertificate
+	}
+
+	h.server = http.Server{
+		Addr:         h.ServiceAddress,
+		Handler:      h,
+		ReadTimeout:  h.ReadTimeout.Duration,
+		WriteTimeout: h.WriteTimeout.Duration,
+		TLSConfig:    tlsConf,
+		ConnState:    h.trackConn,
+		ErrorLog:     log.New(&serverErrorLog{h: h}, "", 0),
+	}
+	h.conns = make(map[string]*trackedConn)
+	h.ipConns = make(map[string]int)
+
+	listener, err := h.listen()
+	if err != nil {
+		return err
+	}
+	if tlsConf != nil {
+		listener = &tlsListener{Listener: listener, config: tlsConf, handshakeErrors: h.tlsHandshakeErrors}
+	}
+	h.listener = listener
+	h.port = listener.Addr().(*net.TCPAddr).Port
+
+	h.started = time.Now()
+	if h.HeartbeatInterval.Duration > 0 {
+		h.heartbeatDone = make(chan struct{})
+		h.heartbeatWg.Add(1)
+		go h.heartbeat()
+	}
+	if h.FlushInterval.Duration > 0 {
+		h.flushDone = make(chan struct{})
+		h.flushWg.Add(1)
+		go h.flusher()
+	}
+
+	// Connections accepted by the listener wait in its backlog until the
+	// server serves them, which is only once everything the handlers use,
+	// including the routes set in Init, is ready.
+	go func() {
+		err := h.server.Serve(h.listener)
+		if err != http.ErrServerClosed {
+			h.Log.Infof("Error serving HTTP on %s", h.ServiceAddress)
+		}
+	}()
+
+	h.Log.Infof("Started HTTP listener service on %s", h.ServiceAddress)
+
+	return nil
+}
+
+// getCertificate returns the certificate for the server name asked for by
+// the client.  Returning no certificate falls back to tls_cert.
+func (h *InfluxDBListener) getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return h.sniCerts[strings.ToLower(hello.ServerName)], nil
+}
+
+// loadCRL loads the certificates revoked by tls_crl_file, unless the file
+// is unchanged since it was last loaded.
+func (h *InfluxDBListener) loadCRL() error {
+	info, err := os.Stat(h.TLSCRLFile)
+	if err != nil {
+		return err
+	}
+	h.crlMu.RLock()
+	unchanged := h.crlRevoked != nil && info.ModTime().Equal(h.crlModTime)
+	h.crlMu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(h.TLSCRLFile)
+	if err != nil {
+		return err
+	}
+	crl, err := x509.ParseCRL(data)
+	if err != nil {
+		return err
+	}
+	if crl.HasExpired(time.Now()) {
+		h.Log.Warnf("The certificate revocation list %s has expired", h.TLSCRLFile)
+	}
+
+	var issuer pkix.Name
+	issuer.FillFromRDNSequence(&crl.TBSCertList.Issuer)
+	revoked := make(map[string]bool)
+	for _, cert := range crl.TBSCertList.RevokedCertificates {
+		revoked[issuer.String()+"/"+cert.SerialNumber.String()] = true
+	}
+
+	h.crlMu.Lock()
+	h.crlRevoked = revoked
+	h.crlModTime = info.ModTime()
+	h.crlMu.Unlock()
+	return nil
+}
+
+// verifyPeerCertificate rejects client certificates revoked by tls_crl_file.
+// It runs after the chains have been verified against the allowed CAs.
+func (h *InfluxDBListener) verifyPeerCertificate(_ [][]byte, verifiedChains [][]*x509.Certificate) error {
+	h.crlMu.RLock()
+	defer h.crlMu.RUnlock()
+
+	for _, chain := range verifiedChains {
+		for _, cert := range chain {
+			if h.crlRevoked[cert.Issuer.String()+"/"+cert.SerialNumber.String()] {
+				h.tlsRevokedCerts.Incr(1)
+				return fmt.Errorf("certificate %s issued by %q is revoked", cert.SerialNumber, cert.Issuer)
+			}
+		}
+	}
+	return nil
+}
+
+// listen opens the TCP listener, with the configured backlog if any.
+func (h *InfluxDBListener) listen() (net.Listener, error) {
+	var listener net.Listener
+	var err error
+	if h.ListenBacklog > 0 {
+		listener, err = listenTCP(h.ServiceAddress, h.ListenBacklog)
+		if err == errBacklogUnsupported {
+			h.Log.Warnf("Option 'listen_backlog' is not supported on this platform and is ignored")
+			listener = nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	if listener == nil {
+		listener, err = net.Listen("tcp", h.ServiceAddress)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &keepAliveListener{Listener: listener, period: h.TCPKeepAlivePeriod.Duration, noDelay: h.TCPNoDelay}, nil
+}
+
+// keepAliveListener sets the TCP keep-alive period of accepted connections,
+// disabling keep-alive if the period is 0, and TCP_NODELAY.  The options are
+// set on each connection, as Go sets TCP_NODELAY on accepted connections
+// regardless of the listening socket.
+type keepAliveListener struct {
+	net.Listener
+	period  time.Duration
+	noDelay bool
+}
+
+func (l *keepAliveListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if tc, ok := conn.(*net.TCPConn); ok {
+		if l.period > 0 {
+			tc.SetKeepAlive(true)
+			tc.SetKeepAlivePeriod(l.period)
+		} else {
+			tc.SetKeepAlive(false)
+		}
+		tc.SetNoDelay(l.noDelay)
+	}
+	return conn, nil
+}
+
+// Stop cleans up all resources
+func (h *InfluxDBListener) Stop() {
+	if h.heartbeatDone != nil {
+		close(h.heartbeatDone)
+		h.heartbeatWg.Wait()
+		h.heartbeatDone = nil
+	}
+
+	err := h.server.Shutdown(context.Background())
+	if err != nil {
+		h.Log.Infof("Error shutting down HTTP server: %v", err.Error())
+	}
+
+	// No more metrics are buffered once the server has shut down.
+	if h.flushDone != nil {
+		close(h.flushDone)
+		h.flushWg.Wait()
+		h.flushDone = nil
+		h.flushBuffer()
+	}
+}
+
+// flusher passes the buffered metrics to the accumulator every
+// flush_interval until the listener is stopped.
+func (h *InfluxDBListener) flusher() {
+	defer h.flushWg.Done()
+
+	ticker := time.NewTicker(h.FlushInterval.Duration)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-h.flushDone:
+			return
+		case <-ticker.C:
+			h.flushBuffer()
+		}
+	}
+}
+
+// bufferMetric buffers a metric for the accumulator, passing the buffer on
+// once it holds flush_max_metrics metrics.
+func (h *InfluxDBListener) bufferMetric(m telegraf.Metric) {
+	h.bufferMu.Lock()
+	defer h.bufferMu.Unlock()
+
+	h.buffer = append(h.buffer, m)
+	if len(h.buffer) >= h.FlushMaxMetrics {
+		h.flushBufferLocked()
+	}
+}
+
+// flushBuffer passes the buffered metrics to the accumulator.
+func (h *InfluxDBListener) flushBuffer() {
+	h.bufferMu.Lock()
+	defer h.bufferMu.Unlock()
+
+	h.flushBufferLocked()
+}
+
+func (h *InfluxDBListener) flushBufferLocked() {
+	if len(h.buffer) == 0 {
+		return
+	}
+
+	start := time.Now()
+	for _, m := range h.buffer {
+		h.acc.AddMetric(m)
+	}
+	h.accumulatorWait.Incr(time.Since(start).Nanoseconds())
+	h.markWritten()
+	h.buffer = nil
+}
+
+// heartbeat adds a metric every heartbeat_interval until the listener is
+// stopped, so that it can be seen to be running without any writes.
+func (h *InfluxDBListener) heartbeat() {
+	defer h.heartbeatWg.Done()
+
+	ticker := time.NewTicker(h.HeartbeatInterval.Duration)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-h.heartbeatDone:
+			return
+		case <-ticker.C:
+			h.acc.AddFields("telegraf_influxdb_listener_heartbeat",
+				map[string]interface{}{
+					"uptime_ns":         time.Since(h.started).Nanoseconds(),
+					"requests_received": h.requestsRecv.Get(),
+					"writes_served":     h.writesServed.Get(),
+					"queries_served":    h.queriesServed.Get(),
+					"pings_served":      h.pingsServed.Get(),
+					"bytes_received":    h.bytesRecv.Get(),
+				},
+				map[string]string{"address": h.ServiceAddress})
+		}
+	}
+}
+
+// withAuth wraps next in authHandler if authentication is required.
+func (h *InfluxDBListener) withAuth(required bool, next http.Handler) http.Handler {
+	if !required {
+		return next
+	}
+	return h.authHandler(next)
+}
+
+// authHandler requires HTTP basic authentication when credentials are
+// configured.  Failures are counted in total and by reason.
+func (h *InfluxDBListener) authHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if h.BasicUsername == "" && h.BasicPassword == "" {
+			next.ServeHTTP(res, req)
+			return
+		}
+
+		username, password, ok := req.BasicAuth()
+		switch {
+		case !ok:
+			h.authMissingCredentials.Incr(1)
+		case subtle.ConstantTimeCompare([]byte(username), []byte(h.BasicUsername)) != 1:
+			h.authBadUsername.Incr(1)
+		case subtle.ConstantTimeCompare([]byte(password), []byte(h.BasicPassword)) != 1:
+			h.authBadPassword.Incr(1)
+		default:
+			next.ServeHTTP(res, req)
+			return
+		}
+
+		h.authFailures.Incr(1)
+		res.Header().Set("WWW-Authenticate", "Basic realm=\"influxdb\"")
+		http.Error(res, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+	})
+}
+
+// postOnly rejects requests with another method than POST if
+// strict_methods is set.
+func (h *InfluxDBListener) postOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if h.StrictMethods && req.Method != http.MethodPost {
+			methodNotAllowed(res, "POST")
+			return
+		}
+		next.ServeHTTP(res, req)
+	})
+}
+
+// writeQueryParams are the query parameters of the write endpoints.
+var writeQueryParams = map[string]bool{
+	"db":          true,
+	"rp":          true,
+	"precision":   true,
+	"consistency": true,
+}
+
+// strictParams rejects requests with an unknown query parameter if
+// strict_query_params is set.
+func (h *InfluxDBListener) strictParams(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if h.StrictQueryParams {
+			if param := h.unknownQueryParam(req.URL.Query()); param != "" {
+				h.badRequest(res, fmt.Sprintf("unknown query parameter %q", param))
+				return
+			}
+		}
+		next.ServeHTTP(res, req)
+	})
+}
+
+// unknownQueryParam returns the first unknown query parameter in sorted
+// order, or an empty string if all of them are known.
+func (h *InfluxDBListener) unknownQueryParam(query url.Values) string {
+	var unknown []string
+	for param := range query {
+		if writeQueryParams[param] {
+			continue
+		}
+		if _, ok := h.QueryParamTags[param]; ok {
+			continue
+		}
+		if param == h.MeasurementSuffixParam {
+			continue
+		}
+		unknown = append(unknown, param)
+	}
+	if len(unknown) == 0 {
+		return ""
+	}
+	sort.Strings(unknown)
+	return unknown[0]
+}
+
+// userAgentHandler rejects requests from blocked user agents, or from user
+// agents not matching required_user_agent.
+func (h *InfluxDBListener) userAgentHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		agent := req.UserAgent()
+		blocked := h.blockedUserAgents != nil && h.blockedUserAgents.Match(agent)
+		if !blocked && h.requiredUserAgent != nil {
+			blocked = !h.requiredUserAgent.Match(agent)
+		}
+		if blocked {
+			h.userAgentsRejected.Incr(1)
+			forbidden(res, fmt.Sprintf("http: writes from client %q are not accepted, please upgrade the client", agent))
+			return
+		}
+		next.ServeHTTP(res, req)
+	})
+}
+
+// maintenanceHandler rejects all requests while in maintenance mode.
+func (h *InfluxDBListener) maintenanceHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if h.MaintenanceMode {
+			serviceUnavailable(res, "http: in maintenance mode", h.MaintenanceRetryAfter.Duration)
+			return
+		}
+		next.ServeHTTP(res, req)
+	})
+}
+
+// contentLengthHandler rejects compressed writes without a Content-Length
+// when require_content_length_for_compressed is set.
+func (h *InfluxDBListener) contentLengthHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if h.RequireContentLengthForCompressed && req.ContentLength < 0 {
+			switch strings.ToLower(req.Header.Get("Content-Encoding")) {
+			case "gzip", "deflate":
+				lengthRequired(res)
+				return
+			}
+		}
+		next.ServeHTTP(res, req)
+	})
+}
+
+// rateLimitHandler rejects writes over global_rate_limit.
+func (h *InfluxDBListener) rateLimitHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if h.rateLimiter != nil {
+			if ok, wait := h.rateLimiter.allow(time.Now()); !ok {
+				h.writesRateLimited.Incr(1)
+				if h.RateLimitRetryAfter.Duration > 0 {
+					wait = h.RateLimitRetryAfter.Duration
+				}
+				rateLimited(res, h.RateLimitStatusCode, "http: too many writes", wait)
+				return
+			}
+		}
+		next.ServeHTTP(res, req)
+	})
+}
+
+// parseLimitHandler rejects writes while max_concurrent_parses writes are
+// already being parsed.
+func (h *InfluxDBListener) parseLimitHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if h.parseSem == nil {
+			next.ServeHTTP(res, req)
+			return
+		}
+
+		select {
+		case h.parseSem <- struct{}{}:
+			defer func() { <-h.parseSem }()
+			next.ServeHTTP(res, req)
+		default:
+			serviceUnavailable(res, "http: too many concurrent writes", time.Second)
+		}
+	})
+}
+
+// dbLimitHandler rejects writes to a database while
+// max_concurrent_writes_per_database writes to it are already being handled.
+func (h *InfluxDBListener) dbLimitHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if h.MaxConcurrentWritesPerDB <= 0 {
+			next.ServeHTTP(res, req)
+			return
+		}
+
+		db := req.URL.Query().Get("db")
+		if !h.acquireDBWrite(db) {
+			serviceUnavailable(res, fmt.Sprintf("http: too many concurrent writes to database %q", db), time.Second)
+			return
+		}
+		defer h.releaseDBWrite(db)
+		next.ServeHTTP(res, req)
+	})
+}
+
+func (h *InfluxDBListener) acquireDBWrite(db string) bool {
+	h.dbWritesMu.Lock()
+	defer h.dbWritesMu.Unlock()
+
+	if h.dbWrites[db] >= h.MaxConcurrentWritesPerDB {
+		return false
+	}
+	h.dbWrites[db]++
+	return true
+}
+
+// releaseDBWrite ends a write to the database, forgetting the database once
+// no more writes to it are being handled.
+func (h *InfluxDBListener) releaseDBWrite(db string) {
+	h.dbWritesMu.Lock()
+	defer h.dbWritesMu.Unlock()
+
+	h.dbWrites[db]--
+	if h.dbWrites[db] <= 0 {
+		delete(h.dbWrites, db)
+	}
+}
+
+// trackedConn is an open connection along with its last known state.
+type trackedConn struct {
+	conn  net.Conn
+	state http.ConnState
+}
+
+// trackConn keeps the open connections by remote address, so that a request
+// running past max_request_duration can be aborted, counts connection state
+// changes and enforces max_connections_per_ip.
+func (h *InfluxDBListener) trackConn(conn net.Conn, state http.ConnState) {
+	h.connsMu.Lock()
+	defer h.connsMu.Unlock()
+
+	addr := conn.RemoteAddr().String()
+	ip := remoteIP(addr)
+	tc, ok := h.conns[addr]
+	if !ok {
+		tc = &trackedConn{conn: conn, state: http.StateNew}
+		h.conns[addr] = tc
+		h.ipConns[ip]++
+	}
+
+	switch tc.state {
+	case http.StateActive:
+		h.connectionsActive.Incr(-1)
+	case http.StateIdle:
+		h.connectionsIdle.Incr(-1)
+	}
+
+	switch state {
+	case http.StateNew:
+		h.connectionsNew.Incr(1)
+		if h.MaxConnectionsPerIP > 0 && h.ipConns[ip] > h.MaxConnectionsPerIP {
+			h.Log.Debugf("Rejecting connection from %s, over the limit of %d connections", addr, h.MaxConnectionsPerIP)
+			h.connectionsRejected.Incr(1)
+			conn.Close()
+		}
+	case http.StateActive:
+		h.connectionsActive.Incr(1)
+		if tc.state == http.StateIdle {
+			h.connectionsReused.Incr(1)
+		}
+	case http.StateIdle:
+		h.connectionsIdle.Incr(1)
+	case http.StateHijacked, http.StateClosed:
+		h.connectionsClosed.Incr(1)
+		delete(h.conns, addr)
+		// Drop the entry of an IP once its last connection is gone, so the
+		// map does not grow with every client ever seen.
+		h.ipConns[ip]--
+		if h.ipConns[ip] <= 0 {
+			delete(h.ipConns, ip)
+		}
+		return
+	}
+	tc.state = state
+}
+
+// remoteIP returns the host part of a remote address.
+func remoteIP(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
+
+// injectDelay waits for inject_response_delay, returning false if the
+// request was canceled in the meantime.
+func (h *InfluxDBListener) injectDelay(req *http.Request) bool {
+	if h.InjectResponseDelay.Duration <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(h.InjectResponseDelay.Duration)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-req.Context().Done():
+		return false
+	}
+}
+
+// requestConn returns the connection the request was received on, or nil if
+// it is not tracked.
+func (h *InfluxDBListener) requestConn(req *http.Request) net.Conn {
+	h.connsMu.Lock()
+	defer h.connsMu.Unlock()
+	tc, ok := h.conns[req.RemoteAddr]
+	if !ok {
+		return nil
+	}
+	return tc.conn
+}
+
+// abortRequest closes the connection the request was received on.
+func (h *InfluxDBListener) abortRequest(req *http.Request) {
+	conn := h.requestConn(req)
+	if conn == nil {
+		return
+	}
+
+	h.Log.Debugf("Aborting request from %s after %s", req.RemoteAddr, h.MaxRequestDuration.Duration)
+	h.requestsAborted.Incr(1)
+	conn.Close()
+}
+
+func (h *InfluxDBListener) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	h.requestsRecv.Incr(1)
+	if h.clientIPs != nil {
+		h.clientIPs.add(remoteIP(req.RemoteAddr))
+	}
+	h.inFlightMu.Lock()
+	h.inFlight[req] = time.Now()
+	h.inFlightMu.Unlock()
+	defer func() {
+		h.inFlightMu.Lock()
+		delete(h.inFlight, req)
+		h.inFlightMu.Unlock()
+	}()
+	if h.MaxRequestDuration.Duration > 0 {
+		timer := time.AfterFunc(h.MaxRequestDuration.Duration, func() {
+			h.abortRequest(req)
+		})
+		defer timer.Stop()
+	}
+	h.trimTrailingSlash(req)
+	for key, value := range h.ResponseHeaders {
+		res.Header().Set(key, value)
+	}
+	if h.GzipErrorResponses && acceptsGzip(req.Header) {
+		gw := &gzipErrorWriter{ResponseWriter: res}
+		defer gw.Close()
+		res = gw
+	}
+	if h.RequireTLS && req.TLS == nil {
+		forbidden(res, "http: TLS required")
+	} else if h.MaxHeaderCount > 0 && headerCount(req.Header) > h.MaxHeaderCount {
+		headerFieldsTooLarge(res)
+	} else if req.Method != http.MethodOptions || !h.handleOptions(res, req) {
+		h.mux.ServeHTTP(res, req)
+	}
+	h.requestsServed.Incr(1)
+}
+
+func (h *InfluxDBListener) handleQuery() http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		defer h.queriesServed.Incr(1)
+		// The body is not used, but is still limited in size as it is read
+		// to keep the connection usable.
+		if req.ContentLength > h.QueryMaxBodySize.Size {
+			tooLarge(res)
+			return
+		}
+		body := http.MaxBytesReader(unwrapResponseWriter(res), req.Body, h.QueryMaxBodySize.Size)
+		if h.EmulateQueryStatements {
+			// The query may be in a form in the body of a POST.  Failing to
+			// read it means it is too large, like below.
+			stream := &streamReader{r: body}
+			req.Body = ioutil.NopCloser(stream)
+			if err := req.ParseForm(); err != nil {
+				if stream.err != nil {
+					tooLarge(res)
+					return
+				}
+				h.badRequest(res, err.Error())
+				return
+			}
+		}
+		if _, err := io.Copy(ioutil.Discard, body); err != nil {
+			tooLarge(res)
+			return
+		}
+
+		// Deliver a dummy response to the query endpoint, as some InfluxDB
+		// clients test endpoint availability with a query
+		res.Header().Set("Content-Type", "application/json")
+		res.Header().Set("X-Influxdb-Version", "1.0")
+		res.WriteHeader(http.StatusOK)
+		if q := req.Form.Get("q"); h.EmulateQueryStatements && q != "" {
+			b, _ := json.Marshal(map[string][]queryResult{"results": emulateQuery(q)})
+			res.Write(b)
+		} else if h.QueryResponseStyle == "compat" {
+			res.Write([]byte("{\"results\":[{\"statement_id\":0,\"series\":[]}]}"))
+		} else {
+			res.Write([]byte("{\"results\":[]}"))
+		}
+	}
+}
+
+func (h *InfluxDBListener) handleDebugMetrics() http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		var metrics []telegraf.Metric
+		for _, m := range selfstat.Metrics() {
+			if m == nil || m.Name() != "internal_influxdb_listener" {
+				continue
+			}
+			if address, _ := m.GetTag("address"); address != h.ServiceAddress {
+				continue
+			}
+			metrics = append(metrics, m)
+		}
+
+		buf, err := serializer.NewSerializer().SerializeBatch(metrics)
+		if err != nil {
+			h.Log.Errorf("Error serializing internal metrics: %v", err)
+			res.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		res.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		res.WriteHeader(http.StatusOK)
+		res.Write(buf)
+	}
+}
+
+// queryResult is the result of a statement of a query.
+type queryResult struct {
+	StatementID int           `json:"statement_id"`
+	Series      []querySeries `json:"series,omitempty"`
+}
+
+type querySeries struct {
+	Name    string   `json:"name"`
+	Columns []string `json:"columns"`
+}
+
+// emulateQuery returns the results InfluxDB gives for the statements of a
+// query.  CREATE DATABASE succeeds without series, as do the statements not
+// emulated.
+func emulateQuery(q string) []queryResult {
+	results := []queryResult{}
+	for _, stmt := range strings.Split(q, ";") {
+		words := strings.Fields(strings.ToUpper(stmt))
+		if len(words) == 0 {
+			continue
+		}
+		result := queryResult{StatementID: len(results)}
+		if len(words) == 2 && words[0] == "SHOW" && words[1] == "DATABASES" {
+			result.Series = []querySeries{{Name: "databases", Columns: []string{"name"}}}
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+func (h *InfluxDBListener) handlePing() http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		defer h.pingsServed.Incr(1)
+		verbose := req.URL.Query().Get("verbose")
+
+		// respond to ping requests
+		res.Header().Set("X-Influxdb-Version", "1.0")
+		if h.PingContentType != "" {
+			res.Header().Set("Content-Type", h.PingContentType)
+		}
+		if verbose != "" && verbose != "0" && verbose != "false" {
+			if h.PingContentType == "" {
+				res.Header().Set("Content-Type", "application/json")
+			}
+			res.WriteHeader(http.StatusOK)
+			b, _ := json.Marshal(map[string]string{"version": "1.0"}) // based on header set above
+			res.Write(b)
+		} else {
+			res.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
+
+func (h *InfluxDBListener) handleHealth() http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		res.WriteHeader(http.StatusOK)
+		res.Write([]byte("OK"))
+	}
+}
+
+func (h *InfluxDBListener) handleReady() http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		if h.MaintenanceMode {
+			serviceUnavailable(res, "http: in maintenance mode", h.MaintenanceRetryAfter.Duration)
+			return
+		}
+		if h.accumulatorSaturated() {
+			serviceUnavailable(res, "http: accumulator saturated", time.Second)
+			return
+		}
+		res.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		res.WriteHeader(http.StatusOK)
+		res.Write([]byte("OK"))
+	}
+}
+
+// accumulatorSaturated reports whether a write has been waiting on the
+// accumulator for longer than readiness_accumulator_wait.
+func (h *InfluxDBListener) accumulatorSaturated() bool {
+	now := time.Now()
+	h.accWaitingMu.Lock()
+	defer h.accWaitingMu.Unlock()
+	for _, start := range h.accWaiting {
+		if now.Sub(start) > h.ReadinessAccumulatorWait.Duration {
+			return true
+		}
+	}
+	return false
+}
+
+// waitAccumulator records that the write is passing metrics to the
+// accumulator until the returned function is called.
+func (h *InfluxDBListener) waitAccumulator(wr *writeRequest) func() {
+	if !h.EnableReadinessEndpoint {
+		return func() {}
+	}
+	h.accWaitingMu.Lock()
+	h.accWaiting[wr] = time.Now()
+	h.accWaitingMu.Unlock()
+	return func() {
+		h.accWaitingMu.Lock()
+		delete(h.accWaiting, wr)
+		h.accWaitingMu.Unlock()
+	}
+}
+
+func (h *InfluxDBListener) handleDefault() http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/" && h.RootResponse.Status != 0 {
+			res.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			res.WriteHeader(h.RootResponse.Status)
+			res.Write([]byte(h.RootResponse.Body))
+			return
+		}
+		defer h.notFoundsServed.Incr(1)
+		http.NotFound(res, req)
+	}
+}
+
+// startWrite returns the settings, the body and the precision of a write,
+// which the caller closes.  If the write cannot be read, it responds to the
+// request and returns false.
+func (h *InfluxDBListener) startWrite(res http.ResponseWriter, req *http.Request) (*writeRequest, io.ReadCloser, string, bool) {
+	// Check that the content length is not too large for us to handle.
+	if req.ContentLength > h.MaxBodySize.Size {
+		tooLarge(res)
+		return nil, nil, "", false
+	}
+
+	precision, err := requestPrecision(req)
+	if err != nil {
+		h.badRequest(res, err.Error())
+		return nil, nil, "", false
+	}
+
+	body, err := h.requestBody(res, req)
+	if err == errTooManyDecompressions {
+		serviceUnavailable(res, err.Error(), time.Second)
+		return nil, nil, "", false
+	}
+	if err != nil {
+		h.Log.Debugf("Error decompressing request body: %v", err.Error())
+		h.badRequest(res, err.Error())
+		return nil, nil, "", false
+	}
+	return h.newWriteRequest(req), body, precision, true
+}
+
+func (h *InfluxDBListener) handleWrite() http.HandlerFunc {
 	return func(res http.ResponseWriter, req *http.Request) {
-		defer h.pingsServed.Incr(1)
-		verbose := req.URL.Query().Get("verbose")
+		defer h.writesServed.Incr(1)
+		start := time.Now()
+		var stream *streamReader
+		if h.SlowWriteThreshold.Duration > 0 {
+			defer func() { h.logSlowWrite(req, stream, start) }()
+		}
+		var idle *idleTimeoutBody
+		if h.BodyReadTimeout.Duration > 0 {
+			if conn := h.requestConn(req); conn != nil {
+				idle = &idleTimeoutBody{ReadCloser: req.Body, conn: conn, timeout: h.BodyReadTimeout.Duration}
+				if h.ReadTimeout.Duration > 0 {
+					idle.limit = start.Add(h.ReadTimeout.Duration)
+				}
+				req.Body = idle
+			}
+		}
+		wr, body, precisionStr, ok := h.startWrite(res, req)
+		if !ok {
+			return
+		}
+		defer body.Close()
+
+		isForm := h.AcceptFormPrecision && isFormEncoded(req)
+
+		var reader io.Reader = body
+		if h.HMACSecret != "" || isForm {
+			buf, err := h.readBody(body)
+			if idle != nil && idle.timedOut {
+				requestTimeout(res)
+				return
+			}
+			if err != nil {
+				h.readError(res, err)
+				return
+			}
+			if h.HMACSecret != "" && !h.validSignature(req, buf) {
+				unauthorized(res, "http: invalid signature")
+				return
+			}
+			reader = bytes.NewReader(buf)
+
+			// Bodies without a data field, such as line protocol sent by
+			// curl without a Content-Type, are parsed as they are.
+			if isForm {
+				form, err := url.ParseQuery(string(buf))
+				if _, ok := form["data"]; ok {
+					if err != nil {
+						h.badRequest(res, err.Error())
+						return
+					}
+					if precisionStr == "" {
+						precisionStr = form.Get("precision")
+					}
+					reader = strings.NewReader(form.Get("data"))
+				}
+			}
+		}
+		if precisionStr == "" {
+			precisionStr = h.DefaultPrecision
+		}
+		var lines *completeLinesReader
+		if h.AccumulateOnReadError {
+			lines = &completeLinesReader{r: reader}
+			reader = lines
+		}
+		if h.MaxOversizedLineBytes.Size > 0 {
+			reader = &lineLimitReader{r: reader, max: h.MaxOversizedLineBytes.Size}
+		}
+		var printable *printableLineReader
+		if h.TolerateTrailingGarbage {
+			printable = &printableLineReader{r: reader}
+			reader = printable
+		}
+		// The parser reports read errors as parse errors, keep track of them
+		// so they end the request rather than being retried.
+		stream = &streamReader{r: reader}
+
+		parser := influx.NewStreamParser(stream)
+		// Points without a timestamp get the server time and are left out
+		// of the clock skew.
+		var defaultTime bool
+		parser.SetTimeFunc(func() time.Time {
+			defaultTime = true
+			return h.timeFunc()
+		})
+
+		precision := time.Nanosecond
+		if precisionStr != "" {
+			precision = getPrecisionMultiplier(precisionStr)
+		}
+		// When detecting the precision, the timestamps are parsed as is and
+		// scaled once the metric is parsed.
+		if h.AutoDetectPrecision {
+			parser.SetTimePrecision(time.Nanosecond)
+		} else if precisionStr != "" {
+			parser.SetTimePrecision(precision)
+		}
+
+		var m telegraf.Metric
+		var err error
+		var lastPos int = 0
+		// Parse errors since the last valid point, held back while they may
+		// turn out to be trailing garbage.
+		var trailingErrs []*influx.ParseError
+		parsed := false
+		count := 0
+		for {
+			select {
+			case <-req.Context().Done():
+				// Shutting down before parsing is finished.  A client going
+				// away also cancels the context, the lines read before are
+				// still parsed if they are to be kept.
+				if lines == nil || lines.err == nil {
+					res.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+			default:
+			}
+
+			defaultTime = false
+			m, err = parser.Next()
+			pos := parser.Position()
+			h.bytesRecv.Incr(int64(pos - lastPos))
+			lastPos = pos
+
+			if stream.err != nil {
+				err = stream.err
+				break
+			}
+
+			// Continue parsing metrics even if some are malformed
+			if parseErr, ok := err.(*influx.ParseError); ok {
+				if h.TolerateTrailingGarbage {
+					trailingErrs = append(trailingErrs, parseErr)
+				} else {
+					h.addParseError(req, wr, parseErr)
+				}
+				continue
+			} else if err != nil {
+				// Either we're exiting cleanly (err ==
+				// influx.EOF) or there's an unexpected error
+				break
+			}
+
+			for _, parseErr := range trailingErrs {
+				h.addParseError(req, wr, parseErr)
+			}
+			trailingErrs = nil
+			parsed = true
+			count++
+			if h.MaxMetricsPerRequest > 0 && count > h.MaxMetricsPerRequest {
+				err = errTooManyMetrics
+				break
+			}
+
+			if h.RequireTimestamp && defaultTime {
+				h.metricsNoTimestamp.Incr(1)
+				wr.addError(fmt.Errorf("metric %q has no timestamp", m.Name()))
+				continue
+			}
+			if h.AutoDetectPrecision {
+				if err := h.scaleTimestamp(m, precision, defaultTime); err != nil {
+					wr.addError(err)
+					continue
+				}
+			}
+			if h.TrackClockSkew && !defaultTime {
+				h.recordClockSkew(m.Time())
+			}
+			h.writeMetric(m, wr)
+		}
+		if len(trailingErrs) > 0 {
+			// Only lines past the last one with a printable character are
+			// garbage, anything else is a malformed point.
+			garbage := parsed
+			for _, parseErr := range trailingErrs {
+				if parseErr.LineNumber <= printable.last {
+					garbage = false
+				}
+			}
+			if garbage {
+				h.Log.Debugf("Ignoring trailing garbage: %v", h.redact(trailingErrs[0].Error()))
+			} else {
+				for _, parseErr := range trailingErrs {
+					h.addParseError(req, wr, parseErr)
+				}
+			}
+		}
+		if idle != nil && idle.timedOut {
+			h.Log.Debugf("Timed out reading the request body from %s", req.RemoteAddr)
+			requestTimeout(res)
+			return
+		}
+		if !h.injectDelay(req) {
+			res.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if err == errLineTooLong {
+			h.Log.Debugf("Error parsing the request body: %v", h.redact(err.Error()))
+			lineTooLong(res)
+			return
+		}
+		if err == errDecompressionRatio {
+			h.Log.Debugf("Error parsing the request body: %v", h.redact(err.Error()))
+			decompressionRatioTooHigh(res)
+			return
+		}
+		if err != influx.EOF {
+			h.Log.Debugf("Error parsing the request body: %v", h.redact(err.Error()))
+			h.badRequest(res, err.Error())
+			return
+		}
+		if lines != nil && lines.err != nil {
+			if lines.err == errDecompressionRatio {
+				decompressionRatioTooHigh(res)
+				return
+			}
+			h.Log.Debugf("Error reading the request body: %v", lines.err.Error())
+			h.partialWrite(res, fmt.Sprintf("partial write: reading the body failed after %d bytes: %v", stream.n, lines.err))
+			return
+		}
+		if h.RejectEmptyWrites && stream.n == 0 {
+			h.badRequest(res, "http: empty write")
+			return
+		}
+		if wr.errorCount > 0 {
+			h.partialWrite(res, wr.partialErrorString())
+			return
+		}
+
+		// http request success
+		if h.PointCountHeader {
+			res.Header().Set("X-Points-Written", strconv.Itoa(wr.written))
+		}
+		res.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (h *InfluxDBListener) handleWriteNDJSON() http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		defer h.writesServed.Incr(1)
+		wr, body, precisionStr, ok := h.startWrite(res, req)
+		if !ok {
+			return
+		}
+		defer body.Close()
+
+		var reader io.Reader = body
+		if h.HMACSecret != "" {
+			buf, err := h.readBody(body)
+			if err != nil {
+				h.readError(res, err)
+				return
+			}
+			if !h.validSignature(req, buf) {
+				unauthorized(res, "http: invalid signature")
+				return
+			}
+			reader = bytes.NewReader(buf)
+		}
+		if h.MaxOversizedLineBytes.Size > 0 {
+			reader = &lineLimitReader{r: reader, max: h.MaxOversizedLineBytes.Size}
+		}
+		stream := &streamReader{r: reader}
+		lines := bufio.NewReader(stream)
+
+		if precisionStr == "" {
+			precisionStr = h.DefaultPrecision
+		}
+		precision := time.Nanosecond
+		if precisionStr != "" {
+			precision = getPrecisionMultiplier(precisionStr)
+		}
+
+		// Like line protocol, the body is read one line at a time and a
+		// malformed line does not stop the others from being written.
+		var err error
+		lineNum := 0
+		count := 0
+		for {
+			select {
+			case <-req.Context().Done():
+				res.WriteHeader(http.StatusServiceUnavailable)
+				return
+			default:
+			}
+
+			var line []byte
+			line, err = lines.ReadBytes('\n')
+			if err != nil && err != io.EOF {
+				break
+			}
+			lineNum++
+			if line = bytes.TrimSpace(line); len(line) > 0 {
+				m, parseErr := h.parseNDJSON(line, precision)
+				if parseErr != nil {
+					wr.addError(fmt.Errorf("line %d: %v", lineNum, parseErr))
+				} else {
+					count++
+					if h.MaxMetricsPerRequest > 0 && count > h.MaxMetricsPerRequest {
+						err = errTooManyMetrics
+						break
+					}
+					h.writeMetric(m, wr)
+				}
+			}
+			if err == io.EOF {
+				err = nil
+				break
+			}
+		}
+		h.bytesRecv.Incr(stream.n)
+
+		if err == errLineTooLong {
+			h.Log.Debugf("Error parsing the request body: %v", h.redact(err.Error()))
+			lineTooLong(res)
+			return
+		}
+		if err != nil {
+			h.readError(res, err)
+			return
+		}
+		if h.RejectEmptyWrites && stream.n == 0 {
+			h.badRequest(res, "http: empty write")
+			return
+		}
+		if wr.errorCount > 0 {
+			h.partialWrite(res, wr.partialErrorString())
+			return
+		}
+
+		// http request success
+		res.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ndjsonMetric is a metric sent to the /write/ndjson endpoint.
+type ndjsonMetric struct {
+	Name      string                 `json:"name"`
+	Tags      map[string]string      `json:"tags"`
+	Fields    map[string]interface{} `json:"fields"`
+	Timestamp *json.Number           `json:"timestamp"`
+}
+
+// parseNDJSON creates a metric from a line of the /write/ndjson endpoint.
+// Timestamps are integers in the precision of the write; metrics without a
+// timestamp get the current time.
+func (h *InfluxDBListener) parseNDJSON(line []byte, precision time.Duration) (telegraf.Metric, error) {
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+	var obj ndjsonMetric
+	if err := dec.Decode(&obj); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, errors.New("unexpected data after the object")
+	}
+	if obj.Name == "" {
+		return nil, errors.New("missing name")
+	}
+	if len(obj.Fields) == 0 {
+		return nil, fmt.Errorf("metric %q has no fields", obj.Name)
+	}
+
+	fields := make(map[string]interface{}, len(obj.Fields))
+	for key, value := range obj.Fields {
+		switch v := value.(type) {
+		case json.Number:
+			if i, err := v.Int64(); err == nil {
+				fields[key] = i
+			} else if f, err := v.Float64(); err == nil {
+				fields[key] = f
+			} else {
+				return nil, fmt.Errorf("invalid number for field %q: %v", key, err)
+			}
+		case string, bool:
+			fields[key] = v
+		default:
+			return nil, fmt.Errorf("unsupported value for field %q", key)
+		}
+	}
+
+	if obj.Timestamp == nil && h.RequireTimestamp {
+		h.metricsNoTimestamp.Incr(1)
+		return nil, fmt.Errorf("metric %q has no timestamp", obj.Name)
+	}
+	t := h.timeFunc()
+	if obj.Timestamp != nil {
+		ts, err := obj.Timestamp.Int64()
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp %q", obj.Timestamp.String())
+		}
+		t, err = unixTime(ts, precision)
+		if err != nil {
+			return nil, fmt.Errorf("metric %q: %v", obj.Name, err)
+		}
+	}
+	return metric.New(obj.Name, obj.Tags, fields, t)
+}
+
+// logSlowWrite warns about a write that took longer than
+// slow_write_threshold.
+func (h *InfluxDBListener) logSlowWrite(req *http.Request, stream *streamReader, start time.Time) {
+	elapsed := time.Since(start)
+	if elapsed < h.SlowWriteThreshold.Duration {
+		return
+	}
+	var n int64
+	if stream != nil {
+		n = stream.n
+	}
+	h.Log.Warnf("Slow write to %s from %s: %d bytes in %s", req.URL.Path, req.RemoteAddr, n, elapsed)
+}
+
+func (h *InfluxDBListener) handleWriteCSV() http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		defer h.writesServed.Incr(1)
+		wr, body, _, ok := h.startWrite(res, req)
+		if !ok {
+			return
+		}
+		defer body.Close()
+
+		buf, err := h.readBody(body)
+		h.bytesRecv.Incr(int64(len(buf)))
+		if err != nil {
+			h.readError(res, err)
+			return
+		}
+		if h.RejectEmptyWrites && len(buf) == 0 {
+			h.badRequest(res, "http: empty write")
+			return
+		}
+		if h.HMACSecret != "" && !h.validSignature(req, buf) {
+			unauthorized(res, "http: invalid signature")
+			return
+		}
+
+		// The CSV parser keeps the column names found in the header, so a
+		// new one is needed for every request.
+		parser := &csv.Parser{
+			MetricName:        h.CSVMetricName,
+			HeaderRowCount:    h.CSVHeaderRowCount,
+			Delimiter:         h.CSVDelimiter,
+			ColumnNames:       append([]string(nil), h.CSVColumnNames...),
+			ColumnTypes:       h.CSVColumnTypes,
+			TagColumns:        h.CSVTagColumns,
+			MeasurementColumn: h.CSVMeasurementColumn,
+			TimestampColumn:   h.CSVTimestampColumn,
+			TimestampFormat:   h.CSVTimestampFormat,
+			TimeFunc:          h.timeFunc,
+		}
+
+		// On a malformed row the parser returns the rows before it along
+		// with the error; keep those as the line protocol endpoint does.
+		metrics, err := parser.Parse(buf)
+		for _, m := range metrics {
+			if len(h.CSVFieldColumns) > 0 {
+				var remove []string
+				for _, field := range m.FieldList() {
+					if !contains(h.CSVFieldColumns, field.Key) {
+						remove = append(remove, field.Key)
+					}
+				}
+				for _, key := range remove {
+					m.RemoveField(key)
+				}
+			}
+
+			h.writeMetric(m, wr)
+		}
+		if err != nil {
+			h.Log.Debugf("Error parsing the request body: %v", h.redact(err.Error()))
+			h.badRequest(res, err.Error())
+			return
+		}
+		if wr.errorCount > 0 {
+			h.partialWrite(res, wr.partialErrorString())
+			return
+		}
+
+		// http request success
+		res.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (h *InfluxDBListener) handleWritePrometheus() http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		defer h.writesServed.Incr(1)
+		wr, body, _, ok := h.startWrite(res, req)
+		if !ok {
+			return
+		}
+		defer body.Close()
+
+		buf, err := h.readBody(body)
+		h.bytesRecv.Incr(int64(len(buf)))
+		if err != nil {
+			h.readError(res, err)
+			return
+		}
+		if h.RejectEmptyWrites && len(buf) == 0 {
+			h.badRequest(res, "http: empty write")
+			return
+		}
+		if h.HMACSecret != "" && !h.validSignature(req, buf) {
+			unauthorized(res, "http: invalid signature")
+			return
+		}
+
+		metrics, err := prometheus.Parse(buf, req.Header)
+		if err != nil {
+			h.Log.Debugf("Error parsing the request body: %v", h.redact(err.Error()))
+			h.badRequest(res, err.Error())
+			return
+		}
+		for _, m := range metrics {
+			h.writeMetric(m, wr)
+		}
+		if wr.errorCount > 0 {
+			h.partialWrite(res, wr.partialErrorString())
+			return
+		}
+
+		// http request success
+		res.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// addMetric passes a metric to the accumulator, tracking the time spent
+// waiting on it.  The accumulator blocks when the agent is not keeping up
+// with the incoming metrics.
+func (h *InfluxDBListener) addMetric(m telegraf.Metric) {
+	if h.FlushInterval.Duration > 0 {
+		h.bufferMetric(m)
+		return
+	}
+
+	start := time.Now()
+	h.acc.AddMetric(m)
+	h.accumulatorWait.Incr(time.Since(start).Nanoseconds())
+	h.markWritten()
+}
+
+// markWritten records the time metrics were last passed to the accumulator.
+func (h *InfluxDBListener) markWritten() {
+	h.lastWriteMu.Lock()
+	h.lastWrite = time.Now()
+	h.lastWriteMu.Unlock()
+}
+
+// writeRequest holds the settings of a write request that apply to each of
+// its metrics, and the errors found while writing them.
+type writeRequest struct {
+	db   string
+	tags map[string]string
+	// suffix to append to the measurement names
+	suffix string
+
+	// number of metrics passed, or to be passed, to the accumulator
+	written int
+
+	// distinct tag keys of the accepted metrics
+	tagKeys map[string]bool
+
+	errorCount    int
+	firstErrorStr string
+}
+
+func (wr *writeRequest) addError(err error) {
+	wr.errorCount++
+	if wr.firstErrorStr == "" {
+		wr.firstErrorStr = err.Error()
+	}
+}
+
+// partialErrorString summarizes the errors of a partial write by the first
+// error and the count of the others.
+func (wr *writeRequest) partialErrorString() string {
+	switch wr.errorCount {
+	case 1:
+		return wr.firstErrorStr
+	case 2:
+		return fmt.Sprintf("%s (and 1 other parse error)", wr.firstErrorStr)
+	default:
+		return fmt.Sprintf("%s (and %d other parse errors)", wr.firstErrorStr, wr.errorCount-1)
+	}
+}
+
+func (h *InfluxDBListener) newWriteRequest(req *http.Request) *writeRequest {
+	query := req.URL.Query()
+	wr := &writeRequest{
+		db:   query.Get("db"),
+		tags: make(map[string]string),
+	}
+	if h.MeasurementSuffixParam != "" {
+		wr.suffix = query.Get(h.MeasurementSuffixParam)
+	}
+
+	for header, key := range h.HeaderTags {
+		if value := req.Header.Get(header); value != "" {
+			wr.tags[key] = value
+		}
+	}
+	for param, key := range h.QueryParamTags {
+		if value := query.Get(param); value != "" {
+			wr.tags[key] = value
+		}
+	}
+
+	if h.MaxTrackedDatabases > 0 && wr.db != "" {
+		h.databaseWrites(wr.db).Incr(1)
+	}
+	return wr
+}
+
+// databaseWrites returns the stat counting the writes to a database.  Once
+// max_tracked_databases databases are tracked, writes to other databases are
+// counted together under "other".
+func (h *InfluxDBListener) databaseWrites(db string) selfstat.Stat {
+	h.dbStatsMu.Lock()
+	defer h.dbStatsMu.Unlock()
+
+	if stat, ok := h.dbStats[db]; ok {
+		return stat
+	}
+	if len(h.dbStats) >= h.MaxTrackedDatabases {
+		db = "other"
+		if stat, ok := h.dbStats[db]; ok {
+			return stat
+		}
+	}
+
+	stat := selfstat.Register("influxdb_listener", "database_writes", map[string]string{
+		"address":  h.ServiceAddress,
+		"database": db,
+	})
+	h.dbStats[db] = stat
+	return stat
+}
+
+// measurementWrites returns the stat counting the metrics written to a
+// measurement.  Once max_tracked_measurements measurements are tracked,
+// metrics of other measurements are counted together under "other".
+func (h *InfluxDBListener) measurementWrites(name string) selfstat.Stat {
+	h.measurementStatsMu.Lock()
+	defer h.measurementStatsMu.Unlock()
+
+	if stat, ok := h.measurementStats[name]; ok {
+		return stat
+	}
+	if len(h.measurementStats) >= h.MaxTrackedMeasurements {
+		name = "other"
+		if stat, ok := h.measurementStats[name]; ok {
+			return stat
+		}
+	}
+
+	stat := selfstat.Register("influxdb_listener", "measurement_writes", map[string]string{
+		"address":     h.ServiceAddress,
+		"measurement": name,
+	})
+	h.measurementStats[name] = stat
+	return stat
+}
+
+// writeMetric processes a parsed metric and passes it to the accumulator
+// unless it is dropped.
+func (h *InfluxDBListener) writeMetric(m telegraf.Metric, wr *writeRequest) {
+	keep, err := h.processMetric(m, wr)
+	if err != nil {
+		wr.addError(err)
+		return
+	}
+	if !keep {
+		return
+	}
+	wr.written++
+	if h.PerMeasurementStats {
+		h.measurementWrites(m.Name()).Incr(1)
+	}
+	done := h.waitAccumulator(wr)
+	h.addMetric(m)
+	done()
+}
+
+// processMetric prepares a parsed metric for the accumulator.  It returns
+// false if the metric should be dropped, with an error if the drop should be
+// reported to the client.
+func (h *InfluxDBListener) processMetric(m telegraf.Metric, wr *writeRequest) (bool, error) {
+	if h.TrimWhitespaceKeys || h.TrimWhitespaceTagValues {
+		h.trimWhitespace(m)
+	}
+	if h.RequireValidUTF8 {
+		if err := h.checkUTF8(m); err != nil {
+			return false, err
+		}
+	}
+	if h.SanitizeMeasurementNames {
+		m.SetName(invalidNameChars.ReplaceAllLiteralString(m.Name(), h.ReplacementChar))
+	}
+	if len(h.TagsToFields) > 0 || len(h.FieldsToTags) > 0 {
+		h.moveTagsAndFields(m)
+	}
+	if h.NormalizeBooleans {
+		normalizeBooleans(m)
+	}
+	if err := h.applyKeyPatterns(m); err != nil {
+		return false, err
+	}
+	if err := h.applyMetricLimits(m); err != nil {
+		return false, err
+	}
+	if h.RejectFieldlessMetrics && len(m.FieldList()) == 0 {
+		h.metricsFieldless.Incr(1)
+		return false, fmt.Errorf("metric %q has no fields", m.Name())
+	}
+	if h.MaxDistinctTagKeysPerRequest > 0 {
+		if err := h.checkTagKeys(m, wr); err != nil {
+			return false, err
+		}
+	}
+	if h.MaxNewFieldsPerInterval > 0 {
+		if err := h.checkNewFields(m); err != nil {
+			return false, err
+		}
+	}
+
+	if h.SampleRatio < 1 && rand.Float64() >= h.SampleRatio {
+		h.metricsSampledOut.Incr(1)
+		return false, nil
+	}
+
+	if h.TimestampRound.Duration > 0 {
+		m.SetTime(m.Time().Truncate(h.TimestampRound.Duration))
+	}
+	if d := getPrecisionMultiplier(h.OutputPrecision); d > time.Nanosecond {
+		m.SetTime(m.Time().Truncate(d))
+	}
+	if len(h.FieldConversions) > 0 {
+		h.convertFields(m)
+	}
+	if len(h.FieldTypes) > 0 {
+		if err := h.enforceFieldTypes(m); err != nil {
+			return false, err
+		}
+	}
+	if prefix := h.DatabaseFieldPrefix[wr.db]; prefix != "" {
+		prefixFields(m, prefix)
+	}
+	if wr.suffix != "" {
+		suffix := wr.suffix
+		if h.SanitizeMeasurementNames {
+			suffix = invalidNameChars.ReplaceAllLiteralString(suffix, h.ReplacementChar)
+		}
+		m.SetName(m.Name() + h.MeasurementSuffixSeparator + suffix)
+	}
+
+	for key, value := range wr.tags {
+		m.AddTag(key, value)
+	}
+
+	if h.DatabaseTag != "" && wr.db != "" {
+		m.AddTag(h.DatabaseTag, wr.db)
+	}
+	if h.DBToTag != "" {
+		if wr.db != "" {
+			m.AddTag(h.DBToTag, wr.db)
+		} else {
+			m.RemoveTag(h.DBToTag)
+		}
+	}
+	return true, nil
+}
+
+// convertFields applies the configured conversions to the numeric fields of
+// the metric.
+func (h *InfluxDBListener) convertFields(m telegraf.Metric) {
+	converted := make(map[string]float64)
+	for _, field := range m.FieldList() {
+		conv, ok := h.FieldConversions[m.Name()+"."+field.Key]
+		if !ok {
+			continue
+		}
+
+		var value float64
+		switch v := field.Value.(type) {
+		case float64:
+			value = v
+		case int64:
+			value = float64(v)
+		case uint64:
+			value = float64(v)
+		default:
+			continue
+		}
+		converted[field.Key] = value*conv.Scale + conv.Offset
+	}
+	for key, value := range converted {
+		m.AddField(key, value)
+	}
+}
+
+// moveTagsAndFields turns the tags_to_fields tags into fields and the
+// fields_to_tags fields into tags.
+func (h *InfluxDBListener) moveTagsAndFields(m telegraf.Metric) {
+	fields := make(map[string]interface{})
+	for _, key := range h.TagsToFields {
+		value, ok := m.GetTag(key)
+		if !ok {
+			continue
+		}
+		m.RemoveTag(key)
+		if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+			fields[key] = i
+		} else if f, err := strconv.ParseFloat(value, 64); err == nil {
+			fields[key] = f
+		} else {
+			fields[key] = value
+		}
+	}
+	for _, key := range h.FieldsToTags {
+		value, ok := m.GetField(key)
+		if !ok {
+			continue
+		}
+		m.RemoveField(key)
+		tag, isString := value.(string)
+		if !isString {
+			converted, _ := coerceField(value, "string")
+			tag, _ = converted.(string)
+		}
+		m.AddTag(key, tag)
+	}
+	for key, value := range fields {
+		m.AddField(key, value)
+	}
+}
+
+// enforceFieldTypes checks the fields of the metric against field_types.
+// Values of another type are converted if field_type_mode is "coerce" and
+// the conversion is lossless, otherwise the metric is rejected.
+func (h *InfluxDBListener) enforceFieldTypes(m telegraf.Metric) error {
+	coerced := make(map[string]interface{})
+	for _, field := range m.FieldList() {
+		typ, ok := h.FieldTypes[m.Name()+"."+field.Key]
+		if !ok || fieldType(field.Value) == typ {
+			continue
+		}
+
+		value, ok := coerceField(field.Value, typ)
+		if !ok || h.FieldTypeMode == "drop" {
+			h.metricsWrongFieldType.Incr(1)
+			return fmt.Errorf("field %q of metric %q is not of type %s", field.Key, m.Name(), typ)
+		}
+		coerced[field.Key] = value
+	}
+	for key, value := range coerced {
+		m.AddField(key, value)
+	}
+	return nil
+}
+
+// fieldType returns the field_types name of the type of a field value.
+func fieldType(value interface{}) string {
+	switch value.(type) {
+	case int64, uint64:
+		return "integer"
+	case float64:
+		return "float"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	}
+	return ""
+}
+
+// coerceField converts a field value to a field_types type.  It returns false
+// if the value cannot be converted without losing information.
+func coerceField(value interface{}, typ string) (interface{}, bool) {
+	switch typ {
+	case "integer":
+		switch v := value.(type) {
+		case uint64:
+			if v <= math.MaxInt64 {
+				return int64(v), true
+			}
+		case float64:
+			if v == math.Trunc(v) && v >= math.MinInt64 && v < math.MaxInt64 {
+				return int64(v), true
+			}
+		case string:
+			if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+				return i, true
+			}
+		case bool:
+			if v {
+				return int64(1), true
+			}
+			return int64(0), true
+		}
+	case "float":
+		switch v := value.(type) {
+		case int64:
+			return float64(v), true
+		case uint64:
+			return float64(v), true
+		case string:
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				return f, true
+			}
+		case bool:
+			if v {
+				return float64(1), true
+			}
+			return float64(0), true
+		}
+	case "string":
+		switch v := value.(type) {
+		case int64:
+			return strconv.FormatInt(v, 10), true
+		case uint64:
+			return strconv.FormatUint(v, 10), true
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64), true
+		case bool:
+			return strconv.FormatBool(v), true
+		}
+	case "boolean":
+		switch v := value.(type) {
+		case int64:
+			if v == 0 || v == 1 {
+				return v == 1, true
+			}
+		case uint64:
+			if v == 0 || v == 1 {
+				return v == 1, true
+			}
+		case string:
+			if b, err := strconv.ParseBool(v); err == nil {
+				return b, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// booleanSpellings are the string field values normalize_booleans turns into
+// boolean fields, in lower case.
+var booleanSpellings = map[string]bool{
+	"t":     true,
+	"true":  true,
+	"y":     true,
+	"yes":   true,
+	"on":    true,
+	"1":     true,
+	"f":     false,
+	"false": false,
+	"n":     false,
+	"no":    false,
+	"off":   false,
+	"0":     false,
+}
+
+// normalizeBooleans turns the string fields of the metric spelling a boolean
+// into boolean fields.
+func normalizeBooleans(m telegraf.Metric) {
+	normalized := make(map[string]bool)
+	for _, field := range m.FieldList() {
+		s, ok := field.Value.(string)
+		if !ok {
+			continue
+		}
+		if b, ok := booleanSpellings[strings.ToLower(s)]; ok {
+			normalized[field.Key] = b
+		}
+	}
+	for key, value := range normalized {
+		m.AddField(key, value)
+	}
+}
+
+// prefixFields adds the prefix to the field keys of the metric.
+func prefixFields(m telegraf.Metric, prefix string) {
+	fields := make([]telegraf.Field, 0, len(m.FieldList()))
+	for _, field := range m.FieldList() {
+		fields = append(fields, *field)
+	}
+	for _, field := range fields {
+		m.RemoveField(field.Key)
+	}
+	for _, field := range fields {
+		m.AddField(prefix+field.Key, field.Value)
+	}
+}
+
+// checkUTF8 rejects a metric with invalid UTF-8 in its name, keys or string
+// values, or, if invalid_utf8_mode is "sanitize", replaces the invalid bytes.
+func (h *InfluxDBListener) checkUTF8(m telegraf.Metric) error {
+	valid := utf8.ValidString(m.Name())
+	for _, tag := range m.TagList() {
+		valid = valid && utf8.ValidString(tag.Key) && utf8.ValidString(tag.Value)
+	}
+	for _, field := range m.FieldList() {
+		value, isString := field.Value.(string)
+		valid = valid && utf8.ValidString(field.Key) && (!isString || utf8.ValidString(value))
+	}
+	if valid {
+		return nil
+	}
+
+	h.metricsInvalidUTF8.Incr(1)
+	if h.InvalidUTF8Mode != "sanitize" {
+		return fmt.Errorf("metric %q contains invalid UTF-8", m.Name())
+	}
+
+	m.SetName(validUTF8(m.Name()))
+	tags := make([]telegraf.Tag, 0, len(m.TagList()))
+	for _, tag := range m.TagList() {
+		tags = append(tags, *tag)
+	}
+	for _, tag := range tags {
+		key, value := validUTF8(tag.Key), validUTF8(tag.Value)
+		if key == tag.Key && value == tag.Value {
+			continue
+		}
+		m.RemoveTag(tag.Key)
+		m.AddTag(key, value)
+	}
+	fields := make([]telegraf.Field, 0, len(m.FieldList()))
+	for _, field := range m.FieldList() {
+		fields = append(fields, *field)
+	}
+	for _, field := range fields {
+		key, value := validUTF8(field.Key), field.Value
+		if s, ok := value.(string); ok {
+			value = validUTF8(s)
+		}
+		if key == field.Key && value == field.Value {
+			continue
+		}
+		m.RemoveField(field.Key)
+		m.AddField(key, value)
+	}
+	return nil
+}
+
+// validUTF8 replaces each invalid byte of s with the Unicode replacement
+// character.
+func validUTF8(s string) string {
+	if utf8.ValidString(s) {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			b.WriteRune(utf8.RuneError)
+		} else {
+			b.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+	return b.String()
+}
+
+// trimWhitespace removes surrounding whitespace from the tag and field keys
+// and, if enabled, the tag values of the metric.
+func (h *InfluxDBListener) trimWhitespace(m telegraf.Metric) {
+	tags := make([]telegraf.Tag, 0, len(m.TagList()))
+	for _, tag := range m.TagList() {
+		tags = append(tags, *tag)
+	}
+	for _, tag := range tags {
+		key, value := tag.Key, tag.Value
+		if h.TrimWhitespaceKeys {
+			key = strings.TrimSpace(key)
+		}
+		if h.TrimWhitespaceTagValues {
+			value = strings.TrimSpace(value)
+		}
+		if key == tag.Key && value == tag.Value {
+			continue
+		}
+		m.RemoveTag(tag.Key)
+		if key != tag.Key && m.HasTag(key) {
+			h.Log.Debugf("Trimmed tag key %q of metric %q replaces an existing tag", tag.Key, m.Name())
+		}
+		m.AddTag(key, value)
+	}
+
+	if !h.TrimWhitespaceKeys {
+		return
+	}
+	fields := make([]telegraf.Field, 0, len(m.FieldList()))
+	for _, field := range m.FieldList() {
+		fields = append(fields, *field)
+	}
+	for _, field := range fields {
+		key := strings.TrimSpace(field.Key)
+		if key == field.Key {
+			continue
+		}
+		m.RemoveField(field.Key)
+		if m.HasField(key) {
+			h.Log.Debugf("Trimmed field key %q of metric %q replaces an existing field", field.Key, m.Name())
+		}
+		m.AddField(key, field.Value)
+	}
+}
+
+// applyKeyPatterns checks the tag and field keys against the configured
+// patterns, either rejecting the metric or removing the offending keys.
+func (h *InfluxDBListener) applyKeyPatterns(m telegraf.Metric) error {
+	var badTags, badFields []string
+	if h.tagKeyPattern != nil {
+		for _, tag := range m.TagList() {
+			if !h.tagKeyPattern.MatchString(tag.Key) {
+				badTags = append(badTags, tag.Key)
+			}
+		}
+	}
+	if h.fieldKeyPattern != nil {
+		for _, field := range m.FieldList() {
+			if !h.fieldKeyPattern.MatchString(field.Key) {
+				badFields = append(badFields, field.Key)
+			}
+		}
+	}
+	if len(badTags) == 0 && len(badFields) == 0 {
+		return nil
+	}
+
+	h.metricsInvalidKeys.Incr(1)
+	if h.KeyPatternMode != "trim" || len(badFields) == len(m.FieldList()) {
+		return fmt.Errorf("metric %q has invalid keys: %s", m.Name(), strings.Join(append(badTags, badFields...), ", "))
+	}
+
+	h.Log.Debugf("Removing invalid keys from metric %q: %s", m.Name(), strings.Join(append(badTags, badFields...), ", "))
+	for _, key := range badTags {
+		m.RemoveTag(key)
+	}
+	for _, key := range badFields {
+		m.RemoveField(key)
+	}
+	return nil
+}
+
+// checkTagKeys rejects the metric if its tag keys would bring the number of
+// distinct tag keys in the request over max_distinct_tag_keys_per_request.
+func (h *InfluxDBListener) checkTagKeys(m telegraf.Metric, wr *writeRequest) error {
+	if wr.tagKeys == nil {
+		wr.tagKeys = make(map[string]bool)
+	}
+
+	var added []string
+	for _, tag := range m.TagList() {
+		if !wr.tagKeys[tag.Key] {
+			added = append(added, tag.Key)
+		}
+	}
+	if len(wr.tagKeys)+len(added) > h.MaxDistinctTagKeysPerRequest {
+		h.metricsOverTagKeyLimit.Incr(1)
+		return fmt.Errorf("metric %q exceeds the limit of %d distinct tag keys per request", m.Name(), h.MaxDistinctTagKeysPerRequest)
+	}
+
+	for _, key := range added {
+		wr.tagKeys[key] = true
+	}
+	return nil
+}
+
+// checkNewFields rejects the metric if its field keys not seen before would
+// bring the number of new field keys of its measurement in this interval over
+// max_new_fields_per_interval.
+func (h *InfluxDBListener) checkNewFields(m telegraf.Metric) error {
+	h.fieldKeysMu.Lock()
+	defer h.fieldKeysMu.Unlock()
+
+	known, ok := h.fieldKeys[m.Name()]
+	if !ok {
+		if len(h.fieldKeys) >= h.MaxTrackedMeasurements {
+			return nil
+		}
+		known = make(map[string]bool)
+		h.fieldKeys[m.Name()] = known
+	}
+
+	var added []string
+	for _, field := range m.FieldList() {
+		if !known[field.Key] {
+			added = append(added, field.Key)
+		}
+	}
+	if h.newFieldKeys[m.Name()]+len(added) > h.MaxNewFieldsPerInterval {
+		h.metricsTooManyNewFields.Incr(1)
+		return fmt.Errorf("metric %q exceeds the limit of %d new fields per interval", m.Name(), h.MaxNewFieldsPerInterval)
+	}
+
+	for _, key := range added {
+		known[key] = true
+	}
+	h.newFieldKeys[m.Name()] += len(added)
+	return nil
+}
+
+// applyMetricLimits enforces the maximum number of tags and fields per
+// metric, either by rejecting the metric or trimming the excess.
+func (h *InfluxDBListener) applyMetricLimits(m telegraf.Metric) error {
+	tagList := m.TagList()
+	fieldList := m.FieldList()
+	tooManyTags := h.MaxTagsPerMetric > 0 && len(tagList) > h.MaxTagsPerMetric
+	tooManyFields := h.MaxFieldsPerMetric > 0 && len(fieldList) > h.MaxFieldsPerMetric
+	if !tooManyTags && !tooManyFields {
+		return nil
+	}
+
+	h.metricsOverLimit.Incr(1)
+	if h.MetricLimitMode != "trim" {
+		return fmt.Errorf("metric %q has %d tags and %d fields, exceeding the limit", m.Name(), len(tagList), len(fieldList))
+	}
+
+	h.Log.Debugf("Trimming metric %q with %d tags and %d fields", m.Name(), len(tagList), len(fieldList))
+	if tooManyTags {
+		var remove []string
+		for _, tag := range tagList[h.MaxTagsPerMetric:] {
+			remove = append(remove, tag.Key)
+		}
+		for _, key := range remove {
+			m.RemoveTag(key)
+		}
+	}
+	if tooManyFields {
+		var remove []string
+		for _, field := range fieldList[h.MaxFieldsPerMetric:] {
+			remove = append(remove, field.Key)
+		}
+		for _, key := range remove {
+			m.RemoveField(key)
+		}
+	}
+	return nil
+}
+
+// validSignature checks the X-Signature header of the request against the
+// HMAC-SHA256 of the body.
+func (h *InfluxDBListener) validSignature(req *http.Request, body []byte) bool {
+	signature, err := hex.DecodeString(strings.TrimPrefix(req.Header.Get("X-Signature"), "sha256="))
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(h.HMACSecret))
+	mac.Write(body)
+	return hmac.Equal(signature, mac.Sum(nil))
+}
+
+// requestBody returns the request body limited to the maximum body size and
+// decompressed according to its Content-Encoding.
+func (h *InfluxDBListener) requestBody(res http.ResponseWriter, req *http.Request) (io.ReadCloser, error) {
+	var body io.ReadCloser = http.MaxBytesReader(unwrapResponseWriter(res), req.Body, h.MaxBodySize.Size)
+	gzipped := req.Header.Get("Content-Encoding") == "gzip"
+	if !gzipped && h.DetectGzipByMagic {
+		br := bufio.NewReader(body)
+		magic, _ := br.Peek(len(gzipMagic))
+		gzipped = bytes.Equal(magic, gzipMagic)
+		body = &bufferedBody{Reader: br, Closer: body}
+	}
+
+	if !gzipped {
+		h.requestsPlaintext.Incr(1)
+		return body, nil
+	}
+
+	// Handle gzip request bodies
+	if h.decompressSem != nil {
+		select {
+		case h.decompressSem <- struct{}{}:
+			h.decompressionsActive.Set(int64(len(h.decompressSem)))
+		default:
+			return nil, errTooManyDecompressions
+		}
+	}
+	h.requestsGzip.Incr(1)
+	body = &statReader{ReadCloser: body, stat: h.compressedBytes}
+	input := &timedReader{r: body}
+	if h.MaxDecompressionRatio <= 0 {
+		gz, err := gzip.NewReader(input)
+		if err != nil {
+			h.releaseDecompression()
+			return nil, err
+		}
+		return h.decompressedBody(gz, input), nil
+	}
+
+	compressed := &streamReader{r: input}
+	gz, err := gzip.NewReader(compressed)
+	if err != nil {
+		h.releaseDecompression()
+		return nil, err
+	}
+	return h.decompressedBody(&ratioLimitReader{
+		ReadCloser: gz,
+		compressed: compressed,
+		ratio:      int64(h.MaxDecompressionRatio),
+	}, input), nil
+}
+
+// decompressedBody counts the bytes read from a decompressed body and the
+// time spent decompressing them, and, with max_concurrent_decompressions,
+// releases the token of the body when it is closed.
+func (h *InfluxDBListener) decompressedBody(body io.ReadCloser, input *timedReader) io.ReadCloser {
+	body = &decompressTimer{ReadCloser: body, input: input, stat: h.decompressionTime}
+	body = &statReader{ReadCloser: body, stat: h.decompressedBytes}
+	if h.decompressSem == nil {
+		return body
+	}
+	return &releaseCloser{ReadCloser: body, release: h.releaseDecompression}
+}
+
+// releaseDecompression returns a max_concurrent_decompressions token.
+func (h *InfluxDBListener) releaseDecompression() {
+	if h.decompressSem == nil {
+		return
+	}
+	<-h.decompressSem
+	h.decompressionsActive.Set(int64(len(h.decompressSem)))
+}
+
+// statReader adds the number of bytes read to a stat.
+type statReader struct {
+	io.ReadCloser
+	stat selfstat.Stat
+}
+
+func (r *statReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.stat.Incr(int64(n))
+	return n, err
+}
+
+// timedReader keeps the total time spent reading from r.
+type timedReader struct {
+	r       io.Reader
+	elapsed time.Duration
+}
+
+func (t *timedReader) Read(p []byte) (int, error) {
+	start := time.Now()
+	n, err := t.r.Read(p)
+	t.elapsed += time.Since(start)
+	return n, err
+}
+
+// decompressTimer adds the time spent in reads of a decompressed body to
+// stat, less the time spent waiting for the compressed input.
+type decompressTimer struct {
+	io.ReadCloser
+	input *timedReader
+	stat  selfstat.Stat
+}
+
+func (d *decompressTimer) Read(p []byte) (int, error) {
+	start := time.Now()
+	waited := d.input.elapsed
+	n, err := d.ReadCloser.Read(p)
+	d.stat.Incr((time.Since(start) - (d.input.elapsed - waited)).Nanoseconds())
+	return n, err
+}
+
+// isFormEncoded returns true if the request body is a URL encoded form.
+func isFormEncoded(req *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	return err == nil && mediaType == "application/x-www-form-urlencoded"
+}
+
+// readError responds to an error reading the request body.
+func (h *InfluxDBListener) readError(res http.ResponseWriter, err error) {
+	h.Log.Debugf("Error reading the request body: %v", err.Error())
+	if err == errDecompressionRatio {
+		decompressionRatioTooHigh(res)
+		return
+	}
+	if err == errBodyTooLarge {
+		tooLarge(res)
+		return
+	}
+	h.badRequest(res, err.Error())
+}
 
-		// respond to ping requests
-		res.Header().Set("X-Influxdb-Version", "1.0")
-		if verbose != "" && verbose != "0" && verbose != "false" {
-			res.Header().Set("Content-Type", "application/json")
-			res.WriteHeader(http.StatusOK)
-			b, _ := json.Marshal(map[string]string{"version": "1.0"}) // based on header set above
-			res.Write(b)
-		} else {
-			res.WriteHeader(http.StatusNoContent)
-		}
+// readBody reads a whole request body, failing with errBodyTooLarge once it
+// exceeds max_body_size.  A compressed body is only limited before it is
+// decompressed, so the decompressed size is checked here.
+func (h *InfluxDBListener) readBody(body io.Reader) ([]byte, error) {
+	buf, err := ioutil.ReadAll(io.LimitReader(body, h.MaxBodySize.Size+1))
+	if err == nil && int64(len(buf)) > h.MaxBodySize.Size {
+		return nil, errBodyTooLarge
 	}
+	return buf, err
 }
 
-func (h *InfluxDBListener) handleDefault() http.HandlerFunc {
-	return func(res http.ResponseWriter, req *http.Request) {
-		defer h.notFoundsServed.Incr(1)
-		http.NotFound(res, req)
+// ratioLimitReader fails with errDecompressionRatio once more than ratio
+// times the compressed bytes have been decompressed.
+type ratioLimitReader struct {
+	io.ReadCloser
+	compressed *streamReader
+	ratio      int64
+	n          int64
+}
+
+func (r *ratioLimitReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	if r.n > r.compressed.n*r.ratio {
+		return n, errDecompressionRatio
 	}
+	return n, err
 }
 
-func (h *InfluxDBListener) handleWrite() http.HandlerFunc {
-	return func(res http.ResponseWriter, req *http.Request) {
-		defer h.writesServed.Incr(1)
-		// Check that the content length is not too large for us to handle.
-		if req.ContentLength > h.MaxBodySize.Size {
-			tooLarge(res)
-			return
-		}
+// serverErrorLog passes the errors logged by the HTTP server, such as failed
+// TLS handshakes, to the plugin log at debug level.
+type serverErrorLog struct {
+	h *InfluxDBListener
+}
 
-		db := req.URL.Query().Get("db")
+func (l *serverErrorLog) Write(p []byte) (int, error) {
+	l.h.Log.Debug(strings.TrimSuffix(string(p), "\n"))
+	return len(p), nil
+}
 
-		body := req.Body
-		body = http.MaxBytesReader(res, body, h.MaxBodySize.Size)
-		// Handle gzip request bodies
-		if req.Header.Get("Content-Encoding") == "gzip" {
-			var err error
-			body, err = gzip.NewReader(body)
-			if err != nil {
-				h.Log.Debugf("Error decompressing request body: %v", err.Error())
-				badRequest(res, err.Error())
-				return
-			}
-			defer body.Close()
+// tlsListener wraps accepted connections in TLS and starts their handshake,
+// counting those that fail.  The server's own call to Handshake waits for
+// the same handshake and sees its result, so accepting is never blocked.
+type tlsListener struct {
+	net.Listener
+	config          *tls.Config
+	handshakeErrors selfstat.Stat
+}
+
+func (l *tlsListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	tlsConn := tls.Server(conn, l.config)
+	go func() {
+		if err := tlsConn.Handshake(); err != nil {
+			l.handshakeErrors.Incr(1)
 		}
+	}()
+	return tlsConn, nil
+}
 
-		parser := influx.NewStreamParser(body)
-		parser.SetTimeFunc(h.timeFunc)
+// redact masks the values of the tags in redact_tags_in_logs in a message
+// quoting line protocol.
+func (h *InfluxDBListener) redact(msg string) string {
+	if h.redactedTags == nil {
+		return msg
+	}
+	return h.redactedTags.ReplaceAllString(msg, "${1}***")
+}
 
-		precisionStr := req.URL.Query().Get("precision")
-		if precisionStr != "" {
-			precision := getPrecisionMultiplier(precisionStr)
-			parser.SetTimePrecision(precision)
-		}
+// parseFailureMetricRate is the number of parse failure metrics added per
+// second with emit_parse_failures_as_metrics.
+const parseFailureMetricRate = 10
 
-		var m telegraf.Metric
-		var err error
-		var parseErrorCount int
-		var lastPos int = 0
-		var firstParseErrorStr string
-		for {
-			select {
-			case <-req.Context().Done():
-				// Shutting down before parsing is finished.
-				res.WriteHeader(http.StatusServiceUnavailable)
-				return
-			default:
-			}
+// addParseError reports a line that failed to parse to the client and, with
+// emit_parse_failures_as_metrics, as a metric.
+func (h *InfluxDBListener) addParseError(req *http.Request, wr *writeRequest, parseErr *influx.ParseError) {
+	wr.addError(parseErr)
+	if h.parseFailureLimiter == nil {
+		return
+	}
+	if ok, _ := h.parseFailureLimiter.allow(time.Now()); !ok {
+		return
+	}
 
-			m, err = parser.Next()
-			pos := parser.Position()
-			h.bytesRecv.Incr(int64(pos - lastPos))
-			lastPos = pos
+	tags := map[string]string{
+		"address":   h.ServiceAddress,
+		"client_ip": remoteIP(req.RemoteAddr),
+	}
+	if wr.db != "" {
+		tags["db"] = wr.db
+	}
+	h.acc.AddFields("telegraf_influxdb_listener_parse_failure",
+		map[string]interface{}{
+			"error":  h.redact(parseErr.Error()),
+			"line":   int64(parseErr.LineNumber),
+			"column": int64(parseErr.Column),
+		},
+		tags)
+}
 
-			// Continue parsing metrics even if some are malformed
-			if parseErr, ok := err.(*influx.ParseError); ok {
-				parseErrorCount += 1
-				errStr := parseErr.Error()
-				if firstParseErrorStr == "" {
-					firstParseErrorStr = errStr
-				}
+// rateLimiter is a token bucket allowing rate events per second, with bursts
+// of up to a second worth of events.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64, now time.Time) *rateLimiter {
+	burst := math.Max(rate, 1)
+	return &rateLimiter{rate: rate, burst: burst, tokens: burst, last: now}
+}
+
+// allow takes a token if there is one, otherwise it returns the time until
+// there is.
+func (l *rateLimiter) allow(now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	if l.tokens >= 1 {
+		l.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+}
+
+// acceptsGzip returns whether the Accept-Encoding headers allow a gzipped
+// response.
+func acceptsGzip(header http.Header) bool {
+	for _, value := range header["Accept-Encoding"] {
+		for _, coding := range strings.Split(value, ",") {
+			params := strings.Split(coding, ";")
+			if !strings.EqualFold(strings.TrimSpace(params[0]), "gzip") {
 				continue
-			} else if err != nil {
-				// Either we're exiting cleanly (err ==
-				// influx.EOF) or there's an unexpected error
-				break
 			}
-
-			if h.DatabaseTag != "" && db != "" {
-				m.AddTag(h.DatabaseTag, db)
+			for _, param := range params[1:] {
+				param = strings.TrimSpace(param)
+				if strings.HasPrefix(param, "q=") {
+					if q, err := strconv.ParseFloat(param[2:], 64); err == nil && q == 0 {
+						return false
+					}
+				}
 			}
+			return true
+		}
+	}
+	return false
+}
+
+// gzipErrorWriter gzips the body of responses with an error status code,
+// leaving other responses as they are.
+type gzipErrorWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+	gz          *gzip.Writer
+}
+
+func (w *gzipErrorWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	header := w.Header()
+	header.Add("Vary", "Accept-Encoding")
+	if code >= http.StatusBadRequest {
+		header.Set("Content-Encoding", "gzip")
+		header.Del("Content-Length")
+		w.gz = gzip.NewWriter(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *gzipErrorWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.gz != nil {
+		return w.gz.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush sends the data written so far to the client.
+func (w *gzipErrorWriter) Flush() {
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack lets the caller take over the connection.
+func (w *gzipErrorWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("http: connection cannot be hijacked")
+	}
+	return hijacker.Hijack()
+}
+
+// Close completes the gzipped body, if there is one.
+func (w *gzipErrorWriter) Close() error {
+	if w.gz == nil {
+		return nil
+	}
+	return w.gz.Close()
+}
+
+// unwrapResponseWriter returns the ResponseWriter of the server.  It has to
+// be given to http.MaxBytesReader, which tells the server to close the
+// connection after a body that is too large through an unexported method
+// wrappers cannot forward.
+func unwrapResponseWriter(res http.ResponseWriter) http.ResponseWriter {
+	if w, ok := res.(*gzipErrorWriter); ok {
+		return w.ResponseWriter
+	}
+	return res
+}
+
+// distinctCounterBits is the size of the bitmap of a distinctCounter; its
+// estimates stay within a few percent up to tens of thousands of values.
+const distinctCounterBits = 1 << 16
+
+// distinctCounter estimates the number of distinct values added to it with
+// linear counting, using a fixed size bitmap however many values there are.
+type distinctCounter struct {
+	mu   sync.Mutex
+	bits []uint64
+}
+
+func newDistinctCounter() *distinctCounter {
+	return &distinctCounter{bits: make([]uint64, distinctCounterBits/64)}
+}
+
+func (c *distinctCounter) add(value string) {
+	hash := fnv.New32a()
+	hash.Write([]byte(value))
+	bit := hash.Sum32() % distinctCounterBits
+
+	c.mu.Lock()
+	c.bits[bit/64] |= 1 << (bit % 64)
+	c.mu.Unlock()
+}
+
+// reset returns the estimated number of distinct values added since the
+// last reset, and clears the counter.
+func (c *distinctCounter) reset() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	set := 0
+	for i, word := range c.bits {
+		set += bits.OnesCount64(word)
+		c.bits[i] = 0
+	}
+	if set == 0 {
+		return 0
+	}
+	// With every bit set the estimate is unbounded, so leave one unset.
+	unset := math.Max(float64(distinctCounterBits-set), 1)
+	return int64(math.Round(-distinctCounterBits * math.Log(unset/distinctCounterBits)))
+}
+
+// idleTimeoutBody sets a read deadline on the connection before each read of
+// the request body, so that a read fails once no data has arrived for the
+// timeout.  The deadline is never set past limit, the deadline of
+// read_timeout, if there is one.
+type idleTimeoutBody struct {
+	io.ReadCloser
+	conn     net.Conn
+	timeout  time.Duration
+	limit    time.Time
+	timedOut bool
+}
+
+func (b *idleTimeoutBody) Read(p []byte) (int, error) {
+	deadline := time.Now().Add(b.timeout)
+	capped := !b.limit.IsZero() && b.limit.Before(deadline)
+	if capped {
+		deadline = b.limit
+	}
+	b.conn.SetReadDeadline(deadline)
+	n, err := b.ReadCloser.Read(p)
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() && !capped {
+		b.timedOut = true
+	}
+	return n, err
+}
+
+// releaseCloser calls release once when it is closed.
+type releaseCloser struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (r *releaseCloser) Close() error {
+	r.once.Do(r.release)
+	return r.ReadCloser.Close()
+}
+
+// bufferedBody is a request body read through a buffer.
+type bufferedBody struct {
+	*bufio.Reader
+	io.Closer
+}
 
-			h.acc.AddMetric(m)
+// streamReader counts the bytes read and records the first error, other
+// than io.EOF, returned by the underlying reader.
+type streamReader struct {
+	r   io.Reader
+	n   int64
+	err error
+}
+
+func (s *streamReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	s.n += int64(n)
+	if err != nil && err != io.EOF && s.err == nil {
+		s.err = err
+	}
+	return n, err
+}
 
+// completeLinesReader only passes on complete lines, ending the stream at
+// the last complete line if reading fails.  The read error is kept in err.
+type completeLinesReader struct {
+	r     io.Reader
+	chunk []byte
+	buf   []byte
+	// complete is the length of the complete lines at the start of buf.
+	// Only the data read is scanned for newlines, so that a long line is
+	// not scanned again with every chunk of it.
+	complete int
+	eof      bool
+	err      error
+}
+
+func (l *completeLinesReader) Read(p []byte) (int, error) {
+	if l.chunk == nil {
+		l.chunk = make([]byte, 4096)
+	}
+	for {
+		if l.complete > 0 {
+			n := copy(p, l.buf[:l.complete])
+			l.buf = l.buf[n:]
+			l.complete -= n
+			return n, nil
 		}
-		if err != influx.EOF {
-			h.Log.Debugf("Error parsing the request body: %v", err.Error())
-			badRequest(res, err.Error())
-			return
+		if l.eof && len(l.buf) > 0 {
+			n := copy(p, l.buf)
+			l.buf = l.buf[n:]
+			return n, nil
 		}
-		if parseErrorCount > 0 {
-			var partialErrorString string
-			switch parseErrorCount {
-			case 1:
-				partialErrorString = fmt.Sprintf("%s", firstParseErrorStr)
-			case 2:
-				partialErrorString = fmt.Sprintf("%s (and 1 other parse error)", firstParseErrorStr)
-			default:
-				partialErrorString = fmt.Sprintf("%s (and %d other parse errors)", firstParseErrorStr, parseErrorCount-1)
-			}
-			partialWrite(res, partialErrorString)
-			return
+		if l.eof || l.err != nil {
+			return 0, io.EOF
 		}
 
-		// http request success
-		res.WriteHeader(http.StatusNoContent)
+		n, err := l.r.Read(l.chunk)
+		if i := bytes.LastIndexByte(l.chunk[:n], '\n'); i >= 0 {
+			l.complete = len(l.buf) + i + 1
+		}
+		l.buf = append(l.buf, l.chunk[:n]...)
+		if err == io.EOF {
+			l.eof = true
+		} else if err != nil {
+			l.err = err
+		}
+	}
+}
+
+// lineLimitReader returns errLineTooLong once more than max bytes have been
+// read without a newline.
+type lineLimitReader struct {
+	r    io.Reader
+	max  int64
+	size int64
+}
+
+func (l *lineLimitReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	if i := bytes.LastIndexByte(p[:n], '\n'); i >= 0 {
+		l.size = int64(n - i - 1)
+	} else {
+		l.size += int64(n)
+	}
+	if l.size > l.max {
+		return n, errLineTooLong
+	}
+	return n, err
+}
+
+// printableLineReader records the number of the last line read with a
+// printable character, counting lines as the parser does.
+type printableLineReader struct {
+	r     io.Reader
+	lines int
+	last  int
+}
+
+func (l *printableLineReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	for i := n - 1; i >= 0; i-- {
+		if p[i] > ' ' && p[i] < 0x7f {
+			l.last = l.lines + bytes.Count(p[:i], []byte{'\n'}) + 1
+			break
+		}
+	}
+	l.lines += bytes.Count(p[:n], []byte{'\n'})
+	return n, err
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
 	}
+	return false
 }
 
 func tooLarge(res http.ResponseWriter) {
@@ -351,6 +3966,98 @@ func tooLarge(res http.ResponseWriter) {
 	res.Write([]byte(`{"error":"http: request body too large"}`))
 }
 
+// validHeaderName returns whether name is a valid HTTP header field name,
+// which is a token of RFC 7230.
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.ContainsRune("!#$%&'*+-.^_`|~", c):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// headerCount returns the number of header lines of a request, without the
+// Host header.
+func headerCount(header http.Header) int {
+	count := 0
+	for _, values := range header {
+		count += len(values)
+	}
+	return count
+}
+
+func headerFieldsTooLarge(res http.ResponseWriter) {
+	res.Header().Set("Content-Type", "application/json")
+	res.Header().Set("X-Influxdb-Version", "1.0")
+	res.Header().Set("X-Influxdb-Error", "http: too many request headers")
+	res.WriteHeader(http.StatusRequestHeaderFieldsTooLarge)
+	res.Write([]byte(`{"error":"http: too many request headers"}`))
+}
+
+func lengthRequired(res http.ResponseWriter) {
+	res.Header().Set("Content-Type", "application/json")
+	res.Header().Set("X-Influxdb-Version", "1.0")
+	res.Header().Set("X-Influxdb-Error", "http: compressed body requires a Content-Length")
+	res.WriteHeader(http.StatusLengthRequired)
+	res.Write([]byte(`{"error":"http: compressed body requires a Content-Length"}`))
+}
+
+func decompressionRatioTooHigh(res http.ResponseWriter) {
+	res.Header().Set("Content-Type", "application/json")
+	res.Header().Set("X-Influxdb-Version", "1.0")
+	res.Header().Set("X-Influxdb-Error", errDecompressionRatio.Error())
+	res.Header().Set("Connection", "close")
+	res.WriteHeader(http.StatusRequestEntityTooLarge)
+	res.Write([]byte(fmt.Sprintf(`{"error":%q}`, errDecompressionRatio.Error())))
+}
+
+func requestTimeout(res http.ResponseWriter) {
+	errString := "http: timed out reading the request body"
+	res.Header().Set("Content-Type", "application/json")
+	res.Header().Set("X-Influxdb-Version", "1.0")
+	res.Header().Set("X-Influxdb-Error", errString)
+	res.Header().Set("Connection", "close")
+	res.WriteHeader(http.StatusRequestTimeout)
+	res.Write([]byte(fmt.Sprintf(`{"error":%q}`, errString)))
+}
+
+func lineTooLong(res http.ResponseWriter) {
+	res.Header().Set("Content-Type", "application/json")
+	res.Header().Set("X-Influxdb-Version", "1.0")
+	res.Header().Set("X-Influxdb-Error", errLineTooLong.Error())
+	res.Header().Set("Connection", "close")
+	res.WriteHeader(http.StatusRequestEntityTooLarge)
+	res.Write([]byte(fmt.Sprintf(`{"error":%q}`, errLineTooLong.Error())))
+}
+
+// badRequest responds with a 400 error, hiding the details of errString
+// from the client when error_verbosity is "minimal".
+func (h *InfluxDBListener) badRequest(res http.ResponseWriter, errString string) {
+	if h.ErrorVerbosity == "minimal" {
+		h.Log.Debugf("Bad request: %s", h.redact(errString))
+		errString = "http: bad request"
+	}
+	badRequest(res, errString)
+}
+
+// partialWrite responds with a 400 error for a write where some metrics
+// were rejected, hiding the details of errString from the client when
+// error_verbosity is "minimal".
+func (h *InfluxDBListener) partialWrite(res http.ResponseWriter, errString string) {
+	if h.ErrorVerbosity == "minimal" {
+		h.Log.Debugf("Partial write: %s", h.redact(errString))
+		errString = "partial write: some points were rejected"
+	}
+	partialWrite(res, errString)
+}
+
 func badRequest(res http.ResponseWriter, errString string) {
 	res.Header().Set("Content-Type", "application/json")
 	res.Header().Set("X-Influxdb-Version", "1.0")
@@ -362,6 +4069,56 @@ func badRequest(res http.ResponseWriter, errString string) {
 	res.Write([]byte(fmt.Sprintf(`{"error":%q}`, errString)))
 }
 
+func unauthorized(res http.ResponseWriter, errString string) {
+	res.Header().Set("Content-Type", "application/json")
+	res.Header().Set("X-Influxdb-Version", "1.0")
+	res.Header().Set("X-Influxdb-Error", errString)
+	res.WriteHeader(http.StatusUnauthorized)
+	res.Write([]byte(fmt.Sprintf(`{"error":%q}`, errString)))
+}
+
+func methodNotAllowed(res http.ResponseWriter, allow string) {
+	errString := "http: method not allowed"
+	res.Header().Set("Content-Type", "application/json")
+	res.Header().Set("X-Influxdb-Version", "1.0")
+	res.Header().Set("X-Influxdb-Error", errString)
+	res.Header().Set("Allow", allow)
+	res.WriteHeader(http.StatusMethodNotAllowed)
+	res.Write([]byte(fmt.Sprintf(`{"error":%q}`, errString)))
+}
+
+func forbidden(res http.ResponseWriter, errString string) {
+	res.Header().Set("Content-Type", "application/json")
+	res.Header().Set("X-Influxdb-Version", "1.0")
+	res.Header().Set("X-Influxdb-Error", errString)
+	res.WriteHeader(http.StatusForbidden)
+	res.Write([]byte(fmt.Sprintf(`{"error":%q}`, errString)))
+}
+
+func rateLimited(res http.ResponseWriter, statusCode int, errString string, retryAfter time.Duration) {
+	res.Header().Set("Content-Type", "application/json")
+	res.Header().Set("X-Influxdb-Version", "1.0")
+	res.Header().Set("X-Influxdb-Error", errString)
+	res.Header().Set("Retry-After", retryAfterSeconds(retryAfter))
+	res.WriteHeader(statusCode)
+	res.Write([]byte(fmt.Sprintf(`{"error":%q}`, errString)))
+}
+
+func serviceUnavailable(res http.ResponseWriter, errString string, retryAfter time.Duration) {
+	res.Header().Set("Content-Type", "application/json")
+	res.Header().Set("X-Influxdb-Version", "1.0")
+	res.Header().Set("X-Influxdb-Error", errString)
+	res.Header().Set("Retry-After", retryAfterSeconds(retryAfter))
+	res.WriteHeader(http.StatusServiceUnavailable)
+	res.Write([]byte(fmt.Sprintf(`{"error":%q}`, errString)))
+}
+
+// retryAfterSeconds returns a Retry-After value of at least a second, rounding
+// retryAfter up to whole seconds.
+func retryAfterSeconds(retryAfter time.Duration) string {
+	return strconv.Itoa(int(math.Max(math.Ceil(retryAfter.Seconds()), 1)))
+}
+
 func partialWrite(res http.ResponseWriter, errString string) {
 	res.Header().Set("Content-Type", "application/json")
 	res.Header().Set("X-Influxdb-Version", "1.0")
@@ -370,12 +4127,88 @@ func partialWrite(res http.ResponseWriter, errString string) {
 	res.Write([]byte(fmt.Sprintf(`{"error":%q}`, errString)))
 }
 
+// scaleTimestamp sets the time of a metric parsed with nanosecond precision
+// to its timestamp in the requested precision, or, if that time is not
+// plausible, in the precision giving a plausible time.  It returns an error
+// if the timestamp overflows in the requested precision.
+func (h *InfluxDBListener) scaleTimestamp(m telegraf.Metric, precision time.Duration, defaultTime bool) error {
+	if defaultTime {
+		m.SetTime(m.Time().Truncate(precision))
+		return nil
+	}
+
+	ts := m.Time().UnixNano()
+	if t, ok := plausibleTime(ts, precision); ok {
+		m.SetTime(t)
+		return nil
+	}
+	for _, p := range []time.Duration{time.Second, time.Millisecond, time.Microsecond, time.Nanosecond} {
+		if t, ok := plausibleTime(ts, p); ok {
+			h.Log.Debugf("Timestamp %d of metric %q is not plausible with precision %s, using %s", ts, m.Name(), precision, p)
+			m.SetTime(t)
+			return nil
+		}
+	}
+	t, err := unixTime(ts, precision)
+	if err != nil {
+		return fmt.Errorf("metric %q: %v", m.Name(), err)
+	}
+	m.SetTime(t)
+	return nil
+}
+
+// unixTime returns the time of the timestamp in the precision, or an error
+// if it overflows the nanosecond range of a time.
+func unixTime(ts int64, precision time.Duration) (time.Time, error) {
+	multiplier := int64(precision)
+	if ts > math.MaxInt64/multiplier || ts < math.MinInt64/multiplier {
+		return time.Time{}, fmt.Errorf("timestamp %d is out of range with precision %s", ts, precision)
+	}
+	return time.Unix(0, ts*multiplier), nil
+}
+
+// plausibleTime returns the time of the timestamp in the precision and
+// whether it is plausible.
+func plausibleTime(ts int64, precision time.Duration) (time.Time, bool) {
+	if ts < 0 || ts > maxPlausibleTime.UnixNano()/int64(precision) {
+		return time.Time{}, false
+	}
+	t := time.Unix(0, ts*int64(precision))
+	return t, !t.Before(minPlausibleTime)
+}
+
+// requestPrecision returns the precision of a write from its precision query
+// parameter or, without it, its X-Influx-Precision header.  Unlike the query
+// parameter, an unknown header value is an error.
+func requestPrecision(req *http.Request) (string, error) {
+	if precision := req.URL.Query().Get("precision"); precision != "" {
+		return precision, nil
+	}
+	precision := req.Header.Get("X-Influx-Precision")
+	if !validPrecision(precision) {
+		return "", fmt.Errorf("invalid precision %q", precision)
+	}
+	return precision, nil
+}
+
+// validPrecision reports whether the precision is one of the known values,
+// or empty.
+func validPrecision(precision string) bool {
+	switch precision {
+	case "", "n", "ns", "u", "us", "\u00b5", "\u00b5s", "\u03bc", "\u03bcs", "ms", "s", "m", "h":
+		return true
+	}
+	return false
+}
+
 func getPrecisionMultiplier(precision string) time.Duration {
 	// Influxdb defaults silently to nanoseconds if precision isn't
 	// one of the following:
 	var d time.Duration
 	switch precision {
-	case "u":
+	case "u", "us", "\u00b5", "\u00b5s", "\u03bc", "\u03bcs":
+		// Clients spell microseconds in several ways, including with the
+		// micro sign or the Greek letter mu.
 		d = time.Microsecond
 	case "ms":
 		d = time.Millisecond
@@ -396,13 +4229,21 @@ func init() {
 	inputs.Add("http_listener", func() telegraf.Input {
 		return &InfluxDBListener{
 			ServiceAddress: ":8186",
-			timeFunc:       time.Now,
+			TCPKeepAlivePeriod: internal.Duration{
+				Duration: 15 * time.Second,
+			},
+			TCPNoDelay: true,
+			timeFunc:   time.Now,
 		}
 	})
 	inputs.Add("influxdb_listener", func() telegraf.Input {
 		return &InfluxDBListener{
 			ServiceAddress: ":8186",
-			timeFunc:       time.Now,
+			TCPKeepAlivePeriod: internal.Duration{
+				Duration: 15 * time.Second,
+			},
+			TCPNoDelay: true,
+			timeFunc:   time.Now,
 		}
 	})
 }
@@ -2,28 +2,26 @@ package influxdb_listener
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
 
-	"github.com/influxdata/telegraf/internal"
-	"github.com/influxdata/telegraf/selfstat"
 	"github.com/influxdata/telegraf/testutil"
 )
 
 // newListener is the minimal InfluxDBListener construction to serve writes.
 func newListener() *InfluxDBListener {
 	listener := &InfluxDBListener{
-		timeFunc:     time.Now,
-		acc:          &testutil.NopAccumulator{},
-		bytesRecv:    selfstat.Register("influxdb_listener", "bytes_received", map[string]string{}),
-		writesServed: selfstat.Register("influxdb_listener", "writes_served", map[string]string{}),
-		MaxBodySize: internal.Size{
-			Size: defaultMaxBodySize,
-		},
+		Log:      testutil.Logger{},
+		timeFunc: time.Now,
+	}
+	if err := listener.Init(); err != nil {
+		panic(err)
 	}
+	listener.acc = &testutil.NopAccumulator{}
 	return listener
 }
 
@@ -106,3 +104,35 @@ func lines(lines, numTags, numFields int) string {
 
 	return strings.Join(lp, "\n")
 }
+
+func BenchmarkInfluxDBListener_completeLines(b *testing.B) {
+	benchmarks := []struct {
+		name string
+		body string
+	}{
+		{
+			name: "1M tiny lines",
+			body: strings.Repeat("m v=1\n", 1000000),
+		},
+		{
+			name: "single 10MB line",
+			body: "m,t=" + strings.Repeat("x", 10*1024*1024) + " v=1\n",
+		},
+	}
+
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			b.SetBytes(int64(len(bm.body)))
+			for n := 0; n < b.N; n++ {
+				lines := &completeLinesReader{r: strings.NewReader(bm.body)}
+				data, err := ioutil.ReadAll(lines)
+				if err != nil {
+					b.Error(err)
+				}
+				if len(data) != len(bm.body) {
+					b.Errorf("read %d bytes, expected %d", len(data), len(bm.body))
+				}
+			}
+		})
+	}
+}
@@ -1,19 +1,40 @@
 package influxdb_listener
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
 	"runtime"
 	"strconv"
+	"strings"
 	"sync"
 	"testing"
+	"testing/iotest"
 	"time"
 
+	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/selfstat"
 	"github.com/influxdata/telegraf/testutil"
 
 	"github.com/stretchr/testify/require"
@@ -614,5 +635,4021 @@ func TestWriteParseErrors(t *testing.T) {
 	}
 }
 
+func TestWriteCSV(t *testing.T) {
+	listener := newTestListener()
+	listener.EnableCSVEndpoint = true
+	listener.CSVHeaderRowCount = 1
+	listener.CSVTagColumns = []string{"host"}
+	listener.CSVMeasurementColumn = "measurement"
+	listener.CSVTimestampColumn = "time"
+	listener.CSVTimestampFormat = "unix"
+	listener.CSVFieldColumns = []string{"value"}
+	listener.DatabaseTag = "database"
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	msg := "measurement,host,value,time\ncpu,server01,42,1422568543\ncpu,server02,43,1422568543\n"
+	resp, err := http.Post(createURL(listener, "http", "/write/csv", "db=mydb"), "", bytes.NewBuffer([]byte(msg)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+
+	acc.Wait(2)
+	acc.AssertContainsTaggedFields(t, "cpu",
+		map[string]interface{}{"value": int64(42)},
+		map[string]string{"host": "server01", "database": "mydb"},
+	)
+	acc.AssertContainsTaggedFields(t, "cpu",
+		map[string]interface{}{"value": int64(43)},
+		map[string]string{"host": "server02", "database": "mydb"},
+	)
+	require.Equal(t, int64(1422568543), acc.Metrics[0].Time.Unix())
+}
+
+func TestWriteCSVMalformed(t *testing.T) {
+	listener := newTestListener()
+	listener.EnableCSVEndpoint = true
+	listener.CSVColumnNames = []string{"host", "value"}
+	listener.CSVColumnTypes = []string{"string", "int"}
+	listener.CSVTagColumns = []string{"host"}
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	msg := "server01,42\nserver02,not-a-number\n"
+	resp, err := http.Post(createURL(listener, "http", "/write/csv", ""), "", bytes.NewBuffer([]byte(msg)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 400, resp.StatusCode)
+
+	acc.Wait(1)
+	acc.AssertContainsTaggedFields(t, "influxdb_listener",
+		map[string]interface{}{"value": int64(42)},
+		map[string]string{"host": "server01"},
+	)
+}
+
+func TestWriteCSVGzipBomb(t *testing.T) {
+	listener := newTestListener()
+	listener.EnableCSVEndpoint = true
+	listener.CSVColumnNames = []string{"host", "value"}
+	listener.MaxBodySize = internal.Size{Size: 64 * 1024}
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write(bytes.Repeat([]byte("server01,42\n"), 1024*1024))
+	gz.Close()
+	require.True(t, int64(buf.Len()) < listener.MaxBodySize.Size)
+
+	req, err := http.NewRequest("POST", createURL(listener, "http", "/write/csv", ""), &buf)
+	require.NoError(t, err)
+	req.Header.Set("Content-Encoding", "gzip")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 413, resp.StatusCode)
+	require.Len(t, acc.Metrics, 0)
+}
+
+func TestWriteCSVDisabled(t *testing.T) {
+	listener := newTestListener()
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	resp, err := http.Post(createURL(listener, "http", "/write/csv", ""), "", bytes.NewBuffer([]byte("host,value\nserver01,42\n")))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 404, resp.StatusCode)
+}
+
+func TestWriteBasicAuthFailures(t *testing.T) {
+	listener := newTestAuthListener()
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	tests := []struct {
+		name     string
+		setAuth  func(req *http.Request)
+		expected selfstat.Stat
+	}{
+		{
+			name:     "missing credentials",
+			setAuth:  func(req *http.Request) {},
+			expected: listener.authMissingCredentials,
+		},
+		{
+			name: "bad username",
+			setAuth: func(req *http.Request) {
+				req.SetBasicAuth("bad-username", basicPassword)
+			},
+			expected: listener.authBadUsername,
+		},
+		{
+			name: "bad password",
+			setAuth: func(req *http.Request) {
+				req.SetBasicAuth(basicUsername, "bad-password")
+			},
+			expected: listener.authBadPassword,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failures := listener.authFailures.Get()
+			reasons := map[string]int64{}
+			for _, stat := range []selfstat.Stat{listener.authMissingCredentials, listener.authBadUsername, listener.authBadPassword} {
+				reasons[stat.FieldName()] = stat.Get()
+			}
+
+			req, err := http.NewRequest("POST", createURL(listener, "http", "/write", "db=mydb"), bytes.NewBuffer([]byte(testMsg)))
+			require.NoError(t, err)
+			tt.setAuth(req)
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.EqualValues(t, http.StatusUnauthorized, resp.StatusCode)
+
+			require.Equal(t, failures+1, listener.authFailures.Get())
+			for _, stat := range []selfstat.Stat{listener.authMissingCredentials, listener.authBadUsername, listener.authBadPassword} {
+				expected := reasons[stat.FieldName()]
+				if stat == tt.expected {
+					expected++
+				}
+				require.Equal(t, expected, stat.Get(), stat.FieldName())
+			}
+		})
+	}
+}
+
+func TestWriteMetricLimits(t *testing.T) {
+	tests := []struct {
+		name      string
+		maxTags   int
+		maxFields int
+		mode      string
+		input     string
+		status    int
+		overLimit int64
+		expected  []telegraf.Metric
+	}{
+		{
+			name:      "at limits",
+			maxTags:   2,
+			maxFields: 2,
+			input:     "cpu,a=1,b=2 x=1,y=2 0\n",
+			status:    http.StatusNoContent,
+			expected: []telegraf.Metric{
+				testutil.MustMetric("cpu",
+					map[string]string{"a": "1", "b": "2"},
+					map[string]interface{}{"x": 1.0, "y": 2.0},
+					time.Unix(0, 0)),
+			},
+		},
+		{
+			name:      "too many tags",
+			maxTags:   2,
+			input:     "cpu,a=1,b=2,c=3 x=1 0\ncpu,a=1 x=2 0\n",
+			status:    http.StatusBadRequest,
+			overLimit: 1,
+			expected: []telegraf.Metric{
+				testutil.MustMetric("cpu",
+					map[string]string{"a": "1"},
+					map[string]interface{}{"x": 2.0},
+					time.Unix(0, 0)),
+			},
+		},
+		{
+			name:      "too many fields",
+			maxFields: 2,
+			input:     "cpu,a=1 x=1,y=2,z=3 0\ncpu,a=1 x=2 0\n",
+			status:    http.StatusBadRequest,
+			overLimit: 1,
+			expected: []telegraf.Metric{
+				testutil.MustMetric("cpu",
+					map[string]string{"a": "1"},
+					map[string]interface{}{"x": 2.0},
+					time.Unix(0, 0)),
+			},
+		},
+		{
+			name:      "trim",
+			maxTags:   1,
+			maxFields: 1,
+			mode:      "trim",
+			input:     "cpu,a=1,b=2 x=1,y=2 0\n",
+			status:    http.StatusNoContent,
+			overLimit: 1,
+			expected: []telegraf.Metric{
+				testutil.MustMetric("cpu",
+					map[string]string{"a": "1"},
+					map[string]interface{}{"x": 1.0},
+					time.Unix(0, 0)),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listener := newTestListener()
+			listener.MaxTagsPerMetric = tt.maxTags
+			listener.MaxFieldsPerMetric = tt.maxFields
+			listener.MetricLimitMode = tt.mode
+
+			acc := &testutil.Accumulator{}
+			require.NoError(t, listener.Init())
+			require.NoError(t, listener.Start(acc))
+			defer listener.Stop()
+
+			overLimit := listener.metricsOverLimit.Get()
+
+			resp, err := http.Post(createURL(listener, "http", "/write", ""), "", bytes.NewBuffer([]byte(tt.input)))
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.EqualValues(t, tt.status, resp.StatusCode)
+
+			testutil.RequireMetricsEqual(t, tt.expected, acc.GetTelegrafMetrics())
+			require.Equal(t, overLimit+tt.overLimit, listener.metricsOverLimit.Get())
+		})
+	}
+}
+
+func TestInitInvalidMetricLimitMode(t *testing.T) {
+	listener := newTestListener()
+	listener.MetricLimitMode = "truncate"
+	require.Error(t, listener.Init())
+}
+
+func TestWriteRequireTLS(t *testing.T) {
+	listener := newTestListener()
+	listener.RequireTLS = true
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	resp, err := http.Post(createURL(listener, "http", "/write", "db=mydb"), "", bytes.NewBuffer([]byte(testMsg)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, http.StatusForbidden, resp.StatusCode)
+	require.Equal(t, "http: TLS required", resp.Header.Get("X-Influxdb-Error"))
+	require.Equal(t, 0, len(acc.Metrics))
+}
+
+func TestWriteOversizedLine(t *testing.T) {
+	listener := newTestListener()
+	listener.MaxOversizedLineBytes = internal.Size{Size: 1024}
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	// Stream a line without a newline until the listener gives up, the
+	// writer is stopped when the response has been received.
+	reader, writer := io.Pipe()
+	go func() {
+		writer.Write([]byte(testMsg))
+		writer.Write([]byte("cpu,host=server01 value="))
+		chunk := bytes.Repeat([]byte("1"), 4096)
+		for {
+			if _, err := writer.Write(chunk); err != nil {
+				return
+			}
+		}
+	}()
+	defer writer.Close()
+
+	resp, err := http.Post(createURL(listener, "http", "/write", ""), "", reader)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+	require.Equal(t, "http: line too long", resp.Header.Get("X-Influxdb-Error"))
+
+	acc.Wait(1)
+	acc.AssertContainsTaggedFields(t, "cpu_load_short",
+		map[string]interface{}{"value": float64(12)},
+		map[string]string{"host": "server01"},
+	)
+}
+
+func TestWriteLineWithinLimit(t *testing.T) {
+	listener := newTestListener()
+	listener.MaxOversizedLineBytes = internal.Size{Size: int64(len(testMsg))}
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	resp, err := http.Post(createURL(listener, "http", "/write", ""), "", bytes.NewBuffer([]byte(testMsgs)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+}
+
+func TestWriteRejectEmpty(t *testing.T) {
+	listener := newTestListener()
+	listener.RejectEmptyWrites = true
+	listener.EnableCSVEndpoint = true
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	for _, path := range []string{"/write", "/write/csv"} {
+		resp, err := http.Post(createURL(listener, "http", path, "db=mydb"), "", bytes.NewBuffer([]byte(emptyMsg)))
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.EqualValues(t, 400, resp.StatusCode)
+		require.Equal(t, "http: empty write", resp.Header.Get("X-Influxdb-Error"))
+	}
+
+	// A body of only a newline is not empty
+	resp, err := http.Post(createURL(listener, "http", "/write", "db=mydb"), "", bytes.NewBuffer([]byte("\n")))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+}
+
+func TestWriteHMACSignature(t *testing.T) {
+	listener := newTestListener()
+	listener.HMACSecret = "secret"
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(testMsg))
+	signature := hex.EncodeToString(mac.Sum(nil))
+
+	tests := []struct {
+		name      string
+		body      string
+		signature string
+		gzip      bool
+		status    int
+	}{
+		{
+			name:      "valid signature",
+			body:      testMsg,
+			signature: signature,
+			status:    http.StatusNoContent,
+		},
+		{
+			name:      "valid prefixed signature",
+			body:      testMsg,
+			signature: "sha256=" + signature,
+			status:    http.StatusNoContent,
+		},
+		{
+			name:      "valid signature of gzipped body",
+			body:      testMsg,
+			signature: signature,
+			gzip:      true,
+			status:    http.StatusNoContent,
+		},
+		{
+			name:      "tampered body",
+			body:      "cpu_load_short,host=server01 value=13.0 1422568543702900257\n",
+			signature: signature,
+			status:    http.StatusUnauthorized,
+		},
+		{
+			name:   "missing signature",
+			body:   testMsg,
+			status: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := []byte(tt.body)
+			if tt.gzip {
+				var buf bytes.Buffer
+				w := gzip.NewWriter(&buf)
+				w.Write(body)
+				w.Close()
+				body = buf.Bytes()
+			}
+
+			req, err := http.NewRequest("POST", createURL(listener, "http", "/write", ""), bytes.NewBuffer(body))
+			require.NoError(t, err)
+			if tt.signature != "" {
+				req.Header.Set("X-Signature", tt.signature)
+			}
+			if tt.gzip {
+				req.Header.Set("Content-Encoding", "gzip")
+			}
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.EqualValues(t, tt.status, resp.StatusCode)
+		})
+	}
+}
+
+func TestAccumulatorWaitStat(t *testing.T) {
+	listener := newTestListener()
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	wait := listener.accumulatorWait.Get()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := http.Post(createURL(listener, "http", "/write", ""), "", bytes.NewBuffer([]byte(testMsgs)))
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.EqualValues(t, 204, resp.StatusCode)
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, 25, len(acc.Metrics))
+	require.True(t, listener.accumulatorWait.Get() > wait)
+}
+
+func TestWriteHeaderTags(t *testing.T) {
+	listener := newTestListener()
+	listener.HeaderTags = map[string]string{
+		"X-Tenant":      "tenant",
+		"X-Environment": "environment",
+		"X-Region":      "region",
+	}
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	req, err := http.NewRequest("POST", createURL(listener, "http", "/write", ""), bytes.NewBuffer([]byte(testMsgs)))
+	require.NoError(t, err)
+	req.Header.Set("X-Tenant", "acme")
+	req.Header.Set("X-Environment", "production")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+
+	acc.Wait(5)
+	for _, hostTag := range []string{"server02", "server03", "server04", "server05", "server06"} {
+		acc.AssertContainsTaggedFields(t, "cpu_load_short",
+			map[string]interface{}{"value": float64(12)},
+			map[string]string{"host": hostTag, "tenant": "acme", "environment": "production"},
+		)
+	}
+}
+
+func TestWriteQueryParamTags(t *testing.T) {
+	listener := newTestListener()
+	listener.QueryParamTags = map[string]string{
+		"rp":          "retention_policy",
+		"consistency": "consistency",
+		"u":           "user",
+	}
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	resp, err := http.Post(createURL(listener, "http", "/write", "db=mydb&rp=autogen&consistency=one"), "", bytes.NewBuffer([]byte(testMsgs)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+
+	acc.Wait(5)
+	for _, hostTag := range []string{"server02", "server03", "server04", "server05", "server06"} {
+		acc.AssertContainsTaggedFields(t, "cpu_load_short",
+			map[string]interface{}{"value": float64(12)},
+			map[string]string{"host": hostTag, "retention_policy": "autogen", "consistency": "one"},
+		)
+	}
+}
+
+func TestWriteSampleRatio(t *testing.T) {
+	listener := newTestListener()
+	listener.SampleRatio = 0.5
+
+	acc := &testutil.NopAccumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	sampledOut := listener.metricsSampledOut.Get()
+
+	var buf bytes.Buffer
+	for i := 0; i < 10000; i++ {
+		buf.WriteString(testMsg)
+	}
+	resp, err := http.Post(createURL(listener, "http", "/write", ""), "", &buf)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+
+	dropped := listener.metricsSampledOut.Get() - sampledOut
+	require.True(t, dropped > 4500 && dropped < 5500, "dropped %d of 10000 metrics", dropped)
+}
+
+func TestInitInvalidSampleRatio(t *testing.T) {
+	listener := newTestListener()
+	listener.SampleRatio = 1.5
+	require.Error(t, listener.Init())
+}
+
+func TestWriteMaintenanceMode(t *testing.T) {
+	listener := newTestListener()
+	listener.MaintenanceMode = true
+	listener.MaintenanceRetryAfter = internal.Duration{Duration: 30 * time.Second}
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	resp, err := http.Post(createURL(listener, "http", "/write", "db=mydb"), "", bytes.NewBuffer([]byte(testMsg)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, http.StatusServiceUnavailable, resp.StatusCode)
+	require.Equal(t, "30", resp.Header.Get("Retry-After"))
+	require.Equal(t, 0, len(acc.Metrics))
+
+	resp, err = http.Post(createURL(listener, "http", "/ping", ""), "", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, http.StatusNoContent, resp.StatusCode)
+}
+
+func TestWriteErrorVerbosity(t *testing.T) {
+	var tests = []struct {
+		name      string
+		verbosity string
+		input     string
+		gzip      bool
+		expected  string
+	}{
+		{
+			name:      "full partial write",
+			verbosity: "full",
+			input:     "foo value=1.0\nfoo value=2asdf2.0",
+			expected:  `{"error":"metric parse error: expected field at 2:12: \"foo value=2\""}`,
+		},
+		{
+			name:      "minimal partial write",
+			verbosity: "minimal",
+			input:     "foo value=1.0\nfoo value=2asdf2.0",
+			expected:  `{"error":"partial write: some points were rejected"}`,
+		},
+		{
+			name:      "minimal bad request",
+			verbosity: "minimal",
+			input:     "not gzip",
+			gzip:      true,
+			expected:  `{"error":"http: bad request"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listener := newTestListener()
+			listener.ErrorVerbosity = tt.verbosity
+
+			acc := &testutil.NopAccumulator{}
+			require.NoError(t, listener.Init())
+			require.NoError(t, listener.Start(acc))
+			defer listener.Stop()
+
+			req, err := http.NewRequest("POST", createURL(listener, "http", "/write", ""), bytes.NewBuffer([]byte(tt.input)))
+			require.NoError(t, err)
+			if tt.gzip {
+				req.Header.Set("Content-Encoding", "gzip")
+			}
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			body, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.EqualValues(t, 400, resp.StatusCode)
+			require.Equal(t, tt.expected, string(body))
+		})
+	}
+}
+
+func TestInitInvalidErrorVerbosity(t *testing.T) {
+	listener := newTestListener()
+	listener.ErrorVerbosity = "verbose"
+	require.Error(t, listener.Init())
+}
+
+const testPrometheusMsg = `# HELP http_requests_total The total number of HTTP requests.
+# TYPE http_requests_total counter
+http_requests_total{method="post",code="200"} 1027
+# HELP http_request_duration_seconds A histogram of the request duration.
+# TYPE http_request_duration_seconds histogram
+http_request_duration_seconds_bucket{le="0.1"} 24054
+http_request_duration_seconds_bucket{le="0.5"} 129389
+http_request_duration_seconds_bucket{le="+Inf"} 144320
+http_request_duration_seconds_sum 53423
+http_request_duration_seconds_count 144320
+# HELP rpc_duration_seconds A summary of the RPC duration in seconds.
+# TYPE rpc_duration_seconds summary
+rpc_duration_seconds{quantile="0.5"} 4773
+rpc_duration_seconds{quantile="0.9"} 9001
+rpc_duration_seconds_sum 1.7560473e+07
+rpc_duration_seconds_count 2693
+`
+
+func TestWritePrometheus(t *testing.T) {
+	listener := newTestListener()
+	listener.EnablePrometheusEndpoint = true
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	resp, err := http.Post(createURL(listener, "http", "/write/prometheus", "db=mydb"), "", bytes.NewBuffer([]byte(testPrometheusMsg)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric(
+			"http_requests_total",
+			map[string]string{"method": "post", "code": "200"},
+			map[string]interface{}{"counter": 1027.0},
+			time.Unix(0, 0),
+			telegraf.Counter,
+		),
+		testutil.MustMetric(
+			"http_request_duration_seconds",
+			map[string]string{},
+			map[string]interface{}{
+				"0.1":   24054.0,
+				"0.5":   129389.0,
+				"+Inf":  144320.0,
+				"sum":   53423.0,
+				"count": 144320.0,
+			},
+			time.Unix(0, 0),
+			telegraf.Histogram,
+		),
+		testutil.MustMetric(
+			"rpc_duration_seconds",
+			map[string]string{},
+			map[string]interface{}{
+				"0.5":   4773.0,
+				"0.9":   9001.0,
+				"sum":   1.7560473e+07,
+				"count": 2693.0,
+			},
+			time.Unix(0, 0),
+			telegraf.Summary,
+		),
+	}
+
+	acc.Wait(len(expected))
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(), testutil.SortMetrics(), testutil.IgnoreTime())
+}
+
+func TestWritePrometheusGzipBomb(t *testing.T) {
+	listener := newTestListener()
+	listener.EnablePrometheusEndpoint = true
+	listener.MaxBodySize = internal.Size{Size: 64 * 1024}
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write(bytes.Repeat([]byte("go_goroutines 15\n"), 1024*1024))
+	gz.Close()
+	require.True(t, int64(buf.Len()) < listener.MaxBodySize.Size)
+
+	req, err := http.NewRequest("POST", createURL(listener, "http", "/write/prometheus", ""), &buf)
+	require.NoError(t, err)
+	req.Header.Set("Content-Encoding", "gzip")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 413, resp.StatusCode)
+	require.Len(t, acc.Metrics, 0)
+}
+
+func TestWritePrometheusDisabled(t *testing.T) {
+	listener := newTestListener()
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	resp, err := http.Post(createURL(listener, "http", "/write/prometheus", ""), "", bytes.NewBuffer([]byte(testPrometheusMsg)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 404, resp.StatusCode)
+}
+
+func TestWriteMaxRequestDuration(t *testing.T) {
+	listener := newTestListener()
+	listener.MaxRequestDuration = internal.Duration{Duration: 100 * time.Millisecond}
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	aborted := listener.requestsAborted.Get()
+
+	conn, err := net.Dial("tcp", listener.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// Send only part of the announced body and stall.
+	_, err = conn.Write([]byte("POST /write HTTP/1.1\r\nHost: localhost\r\nContent-Length: 1000\r\n\r\ncpu value=1"))
+	require.NoError(t, err)
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	_, err = ioutil.ReadAll(conn)
+	if err != nil {
+		netErr, ok := err.(net.Error)
+		require.False(t, ok && netErr.Timeout(), "connection was not closed")
+	}
+	require.Equal(t, aborted+1, listener.requestsAborted.Get())
+}
+
+func TestWriteKeyPatterns(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     string
+		input    string
+		status   int
+		invalid  int64
+		expected []telegraf.Metric
+	}{
+		{
+			name:   "matching keys",
+			input:  "cpu,host=a usage_idle=1 0\n",
+			status: http.StatusNoContent,
+			expected: []telegraf.Metric{
+				testutil.MustMetric("cpu",
+					map[string]string{"host": "a"},
+					map[string]interface{}{"usage_idle": 1.0},
+					time.Unix(0, 0)),
+			},
+		},
+		{
+			name:    "invalid tag key",
+			input:   "cpu,Host=a usage_idle=1 0\ncpu,host=a usage_idle=2 0\n",
+			status:  http.StatusBadRequest,
+			invalid: 1,
+			expected: []telegraf.Metric{
+				testutil.MustMetric("cpu",
+					map[string]string{"host": "a"},
+					map[string]interface{}{"usage_idle": 2.0},
+					time.Unix(0, 0)),
+			},
+		},
+		{
+			name:    "invalid field key",
+			input:   "cpu,host=a usage-idle=1 0\n",
+			status:  http.StatusBadRequest,
+			invalid: 1,
+		},
+		{
+			name:    "trim",
+			mode:    "trim",
+			input:   "cpu,host=a,Region=b usage_idle=1,usage-user=2 0\n",
+			status:  http.StatusNoContent,
+			invalid: 1,
+			expected: []telegraf.Metric{
+				testutil.MustMetric("cpu",
+					map[string]string{"host": "a"},
+					map[string]interface{}{"usage_idle": 1.0},
+					time.Unix(0, 0)),
+			},
+		},
+		{
+			name:    "trim without valid fields",
+			mode:    "trim",
+			input:   "cpu,host=a usage-idle=1 0\n",
+			status:  http.StatusBadRequest,
+			invalid: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listener := newTestListener()
+			listener.TagKeyPattern = "^[a-z0-9_]+$"
+			listener.FieldKeyPattern = "^[a-z0-9_]+$"
+			listener.KeyPatternMode = tt.mode
+
+			acc := &testutil.Accumulator{}
+			require.NoError(t, listener.Init())
+			require.NoError(t, listener.Start(acc))
+			defer listener.Stop()
+
+			invalid := listener.metricsInvalidKeys.Get()
+
+			resp, err := http.Post(createURL(listener, "http", "/write", ""), "", bytes.NewBuffer([]byte(tt.input)))
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.EqualValues(t, tt.status, resp.StatusCode)
+
+			testutil.RequireMetricsEqual(t, tt.expected, acc.GetTelegrafMetrics())
+			require.Equal(t, invalid+tt.invalid, listener.metricsInvalidKeys.Get())
+		})
+	}
+}
+
+func TestInitInvalidKeyPattern(t *testing.T) {
+	listener := newTestListener()
+	listener.TagKeyPattern = "[a-z"
+	err := listener.Init()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "tag_key_pattern")
+
+	listener = newTestListener()
+	listener.KeyPatternMode = "remove"
+	require.Error(t, listener.Init())
+}
+
+func TestWriteTrimWhitespaceKeys(t *testing.T) {
+	tests := []struct {
+		name       string
+		trimValues bool
+		input      string
+		expected   []telegraf.Metric
+	}{
+		{
+			name:  "padded keys",
+			input: "cpu,\\ host\\ =a\\ ,region=b \\ value\\ =1 0\n",
+			expected: []telegraf.Metric{
+				testutil.MustMetric("cpu",
+					map[string]string{"host": "a ", "region": "b"},
+					map[string]interface{}{"value": 1.0},
+					time.Unix(0, 0)),
+			},
+		},
+		{
+			name:       "padded tag values",
+			trimValues: true,
+			input:      "cpu,\\ host=a\\  value=1 0\n",
+			expected: []telegraf.Metric{
+				testutil.MustMetric("cpu",
+					map[string]string{"host": "a"},
+					map[string]interface{}{"value": 1.0},
+					time.Unix(0, 0)),
+			},
+		},
+		{
+			name:  "collision",
+			input: "cpu value=1,value\\ =2 0\n",
+			expected: []telegraf.Metric{
+				testutil.MustMetric("cpu",
+					map[string]string{},
+					map[string]interface{}{"value": 2.0},
+					time.Unix(0, 0)),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listener := newTestListener()
+			listener.TrimWhitespaceKeys = true
+			listener.TrimWhitespaceTagValues = tt.trimValues
+
+			acc := &testutil.Accumulator{}
+			require.NoError(t, listener.Init())
+			require.NoError(t, listener.Start(acc))
+			defer listener.Stop()
+
+			resp, err := http.Post(createURL(listener, "http", "/write", ""), "", bytes.NewBuffer([]byte(tt.input)))
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.EqualValues(t, 204, resp.StatusCode)
+
+			testutil.RequireMetricsEqual(t, tt.expected, acc.GetTelegrafMetrics())
+		})
+	}
+}
+
+func TestWriteDBToTag(t *testing.T) {
+	listener := newTestListener()
+	listener.DBToTag = "route"
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	resp, err := http.Post(createURL(listener, "http", "/write", "db=alerts"), "", bytes.NewBuffer([]byte("cpu,route=other value=1 0\n")))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+
+	resp, err = http.Post(createURL(listener, "http", "/write", ""), "", bytes.NewBuffer([]byte("cpu,route=other value=2 0\n")))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric("cpu",
+			map[string]string{"route": "alerts"},
+			map[string]interface{}{"value": 1.0},
+			time.Unix(0, 0)),
+		testutil.MustMetric("cpu",
+			map[string]string{},
+			map[string]interface{}{"value": 2.0},
+			time.Unix(0, 0)),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics())
+}
+
+func TestWriteTrailingGarbage(t *testing.T) {
+	tests := []struct {
+		name     string
+		tolerate bool
+		input    string
+		status   int
+		metrics  int
+	}{
+		{
+			name:    "strict",
+			input:   "cpu value=1 0\ncpu value=2 0\n\x00\x00\x00",
+			status:  http.StatusBadRequest,
+			metrics: 2,
+		},
+		{
+			name:     "tolerated",
+			tolerate: true,
+			input:    "cpu value=1 0\ncpu value=2 0\n\x00\x00\x00",
+			status:   http.StatusNoContent,
+			metrics:  2,
+		},
+		{
+			name:     "padding followed by a newline",
+			tolerate: true,
+			input:    "cpu value=1 0\ncpu value=2 0\n\x00\x00 \r\n\x00\n",
+			status:   http.StatusNoContent,
+			metrics:  2,
+		},
+		{
+			name:     "malformed last point",
+			tolerate: true,
+			input:    "cpu value=1 0\ncpu value=",
+			status:   http.StatusBadRequest,
+			metrics:  1,
+		},
+		{
+			name:     "malformed last point with a newline",
+			tolerate: true,
+			input:    "cpu value=1 0\ncpu value=\n",
+			status:   http.StatusBadRequest,
+			metrics:  1,
+		},
+		{
+			name:     "malformed point before garbage",
+			tolerate: true,
+			input:    "cpu value=1 0\ncpu value=\n\x00\x00",
+			status:   http.StatusBadRequest,
+			metrics:  1,
+		},
+		{
+			name:     "garbage before valid points",
+			tolerate: true,
+			input:    "\x00\x00\ncpu value=1 0\n",
+			status:   http.StatusBadRequest,
+			metrics:  1,
+		},
+		{
+			name:     "only garbage",
+			tolerate: true,
+			input:    "\x00\x00\x00",
+			status:   http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listener := newTestListener()
+			listener.TolerateTrailingGarbage = tt.tolerate
+
+			acc := &testutil.Accumulator{}
+			require.NoError(t, listener.Init())
+			require.NoError(t, listener.Start(acc))
+			defer listener.Stop()
+
+			resp, err := http.Post(createURL(listener, "http", "/write", ""), "", bytes.NewBuffer([]byte(tt.input)))
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.EqualValues(t, tt.status, resp.StatusCode)
+			require.Equal(t, tt.metrics, len(acc.GetTelegrafMetrics()))
+		})
+	}
+}
+
+func TestWriteListenBacklog(t *testing.T) {
+	listener := newTestListener()
+	listener.ListenBacklog = 16
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	resp, err := http.Post(createURL(listener, "http", "/write", ""), "", bytes.NewBuffer([]byte(testMsg)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+}
+
+func TestOldestInFlightRequestAge(t *testing.T) {
+	listener := newTestListener()
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	require.NoError(t, listener.Gather(acc))
+	require.Equal(t, int64(0), listener.oldestInFlightAge.Get())
+
+	// Keep a write open by not finishing its body.
+	reader, writer := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		resp, err := http.Post(createURL(listener, "http", "/write", ""), "", reader)
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+	_, err := writer.Write([]byte(testMsg))
+	require.NoError(t, err)
+	acc.Wait(1)
+
+	require.NoError(t, listener.Gather(acc))
+	first := listener.oldestInFlightAge.Get()
+	require.True(t, first > 0)
+
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, listener.Gather(acc))
+	require.True(t, listener.oldestInFlightAge.Get() > first)
+
+	writer.Close()
+	<-done
+	require.NoError(t, listener.Gather(acc))
+	require.Equal(t, int64(0), listener.oldestInFlightAge.Get())
+}
+
+func TestQueryResponseStyle(t *testing.T) {
+	tests := []struct {
+		style    string
+		expected string
+	}{
+		{
+			style:    "",
+			expected: `{"results":[]}`,
+		},
+		{
+			style:    "empty",
+			expected: `{"results":[]}`,
+		},
+		{
+			style:    "compat",
+			expected: `{"results":[{"statement_id":0,"series":[]}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.style, func(t *testing.T) {
+			listener := newTestListener()
+			listener.QueryResponseStyle = tt.style
+
+			acc := &testutil.Accumulator{}
+			require.NoError(t, listener.Init())
+			require.NoError(t, listener.Start(acc))
+			defer listener.Stop()
+
+			resp, err := http.Post(createURL(listener, "http", "/query", "q=SHOW+DATABASES"), "", nil)
+			require.NoError(t, err)
+			body, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.EqualValues(t, 200, resp.StatusCode)
+			require.Equal(t, tt.expected, string(body))
+		})
+	}
+}
+
+func TestInitInvalidQueryResponseStyle(t *testing.T) {
+	listener := newTestListener()
+	listener.QueryResponseStyle = "full"
+	require.Error(t, listener.Init())
+}
+
+func TestConnectionStats(t *testing.T) {
+	listener := newTestListener()
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	connsNew := listener.connectionsNew.Get()
+	connsReused := listener.connectionsReused.Get()
+
+	client := &http.Client{Transport: &http.Transport{}}
+	for i := 0; i < 3; i++ {
+		resp, err := client.Post(createURL(listener, "http", "/write", ""), "", bytes.NewBuffer([]byte(testMsg)))
+		require.NoError(t, err)
+		_, err = ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.EqualValues(t, 204, resp.StatusCode)
+	}
+
+	require.Equal(t, connsNew+1, listener.connectionsNew.Get())
+	require.Equal(t, connsReused+2, listener.connectionsReused.Get())
+}
+
+func TestWriteDecompressionRatio(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write([]byte(testMsg))
+	require.NoError(t, err)
+	_, err = gz.Write(bytes.Repeat([]byte("\n"), 10*1024*1024))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+
+	listener := newTestListener()
+	listener.MaxDecompressionRatio = 100
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	req, err := http.NewRequest("POST", createURL(listener, "http", "/write", ""), &buf)
+	require.NoError(t, err)
+	req.Header.Set("Content-Encoding", "gzip")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+	require.Equal(t, "http: decompression ratio too high", resp.Header.Get("X-Influxdb-Error"))
+
+	// A regular compressed write stays well within the ratio.
+	buf.Reset()
+	gz = gzip.NewWriter(&buf)
+	_, err = gz.Write([]byte(testMsgs))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+
+	req, err = http.NewRequest("POST", createURL(listener, "http", "/write", ""), &buf)
+	require.NoError(t, err)
+	req.Header.Set("Content-Encoding", "gzip")
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+}
+
+func TestDebugMetrics(t *testing.T) {
+	listener := newTestListener()
+	listener.EnableDebugMetrics = true
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	resp, err := http.Post(createURL(listener, "http", "/write", ""), "", bytes.NewBuffer([]byte(testMsg)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+
+	resp, err = http.Get(createURL(listener, "http", "/debug/metrics", ""))
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 200, resp.StatusCode)
+	require.Contains(t, string(body), "internal_influxdb_listener,address=localhost:0 ")
+	require.Contains(t, string(body), "writes_served=")
+}
+
+func TestDebugMetricsDisabled(t *testing.T) {
+	listener := newTestListener()
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	resp, err := http.Get(createURL(listener, "http", "/debug/metrics", ""))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 404, resp.StatusCode)
+}
+
+func TestWriteSanitizeMeasurementNames(t *testing.T) {
+	tests := []struct {
+		name        string
+		replacement string
+		input       string
+		expected    string
+	}{
+		{
+			name:     "spaces",
+			input:    "cpu\\ load\\ avg value=1 0\n",
+			expected: "cpu_load_avg",
+		},
+		{
+			name:        "custom replacement",
+			replacement: "-",
+			input:       "disk/io\\,total value=1 0\n",
+			expected:    "disk-io-total",
+		},
+		{
+			name:     "valid name",
+			input:    "net.eth0-rx_bytes value=1 0\n",
+			expected: "net.eth0-rx_bytes",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listener := newTestListener()
+			listener.SanitizeMeasurementNames = true
+			listener.ReplacementChar = tt.replacement
+
+			acc := &testutil.Accumulator{}
+			require.NoError(t, listener.Init())
+			require.NoError(t, listener.Start(acc))
+			defer listener.Stop()
+
+			resp, err := http.Post(createURL(listener, "http", "/write", ""), "", bytes.NewBuffer([]byte(tt.input)))
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.EqualValues(t, 204, resp.StatusCode)
+
+			metrics := acc.GetTelegrafMetrics()
+			require.Equal(t, 1, len(metrics))
+			require.Equal(t, tt.expected, metrics[0].Name())
+		})
+	}
+}
+
+func TestGetPrecisionMultiplier(t *testing.T) {
+	tests := []struct {
+		precision string
+		expected  time.Duration
+	}{
+		{"ns", time.Nanosecond},
+		{"u", time.Microsecond},
+		{"us", time.Microsecond},
+		{"µ", time.Microsecond},
+		{"µs", time.Microsecond},
+		{"μ", time.Microsecond},
+		{"μs", time.Microsecond},
+		{"ms", time.Millisecond},
+		{"s", time.Second},
+		{"m", time.Minute},
+		{"h", time.Hour},
+		{"", time.Nanosecond},
+		{"unknown", time.Nanosecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.precision, func(t *testing.T) {
+			require.Equal(t, tt.expected, getPrecisionMultiplier(tt.precision))
+		})
+	}
+}
+
+func TestWriteWithMicroPrecision(t *testing.T) {
+	for _, precision := range []string{"u", "us", "µ", "µs"} {
+		t.Run(precision, func(t *testing.T) {
+			listener := newTestListener()
+
+			acc := &testutil.Accumulator{}
+			require.NoError(t, listener.Init())
+			require.NoError(t, listener.Start(acc))
+			defer listener.Stop()
+
+			msg := "xyzzy value=42 1422568543702900\n"
+			resp, err := http.Post(
+				createURL(listener, "http", "/write", "precision="+url.QueryEscape(precision)), "", bytes.NewBuffer([]byte(msg)))
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.EqualValues(t, 204, resp.StatusCode)
+
+			acc.Wait(1)
+			require.Equal(t, int64(1422568543702900000), acc.Metrics[0].Time.UnixNano())
+		})
+	}
+}
+
+func TestOpenQueryWithAuthedWrite(t *testing.T) {
+	listener := newTestAuthListener()
+	listener.SkipAuthQuery = true
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	resp, err := http.Post(createURL(listener, "http", "/query", "q=SHOW+DATABASES"), "", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = http.Post(createURL(listener, "http", "/write", "db=mydb"), "", bytes.NewBuffer([]byte(testMsg)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, http.StatusUnauthorized, resp.StatusCode)
+
+	req, err := http.NewRequest("POST", createURL(listener, "http", "/write", "db=mydb"), bytes.NewBuffer([]byte(testMsg)))
+	require.NoError(t, err)
+	req.SetBasicAuth(basicUsername, basicPassword)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, http.StatusNoContent, resp.StatusCode)
+}
+
+func TestOpenWriteWithAuthedQuery(t *testing.T) {
+	listener := newTestAuthListener()
+	listener.SkipAuthWrite = true
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	resp, err := http.Post(createURL(listener, "http", "/write", "db=mydb"), "", bytes.NewBuffer([]byte(testMsg)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, http.StatusNoContent, resp.StatusCode)
+
+	resp, err = http.Post(createURL(listener, "http", "/query", "q=SHOW+DATABASES"), "", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestWriteFieldConversions(t *testing.T) {
+	listener := newTestListener()
+	listener.FieldConversions = map[string]FieldConversion{
+		"weather.temperature": {Scale: 5.0 / 9, Offset: -160.0 / 9},
+		"weather.pressure":    {Offset: 1000},
+	}
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	msg := "weather temperature=212,pressure=13i,station=\"north\" 0\nroom temperature=68 0\n"
+	resp, err := http.Post(createURL(listener, "http", "/write", ""), "", bytes.NewBuffer([]byte(msg)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+
+	metrics := acc.GetTelegrafMetrics()
+	require.Equal(t, 2, len(metrics))
+
+	temperature, ok := metrics[0].GetField("temperature")
+	require.True(t, ok)
+	require.InDelta(t, 100.0, temperature, 1e-9)
+	pressure, ok := metrics[0].GetField("pressure")
+	require.True(t, ok)
+	require.Equal(t, 1013.0, pressure)
+	station, ok := metrics[0].GetField("station")
+	require.True(t, ok)
+	require.Equal(t, "north", station)
+
+	// Conversions only apply to the configured measurement.
+	temperature, ok = metrics[1].GetField("temperature")
+	require.True(t, ok)
+	require.Equal(t, 68.0, temperature)
+}
+
+func TestWriteRejectFieldless(t *testing.T) {
+	for _, reject := range []bool{false, true} {
+		t.Run(strconv.FormatBool(reject), func(t *testing.T) {
+			listener := newTestListener()
+			listener.EnableCSVEndpoint = true
+			listener.CSVHeaderRowCount = 1
+			listener.CSVTagColumns = []string{"host"}
+			listener.CSVFieldColumns = []string{"value"}
+			listener.RejectFieldlessMetrics = reject
+
+			acc := &testutil.Accumulator{}
+			require.NoError(t, listener.Init())
+			require.NoError(t, listener.Start(acc))
+			defer listener.Stop()
+
+			fieldless := listener.metricsFieldless.Get()
+
+			// The only field column is filtered out.
+			msg := "host,other\nserver01,1\n"
+			resp, err := http.Post(createURL(listener, "http", "/write/csv", ""), "", bytes.NewBuffer([]byte(msg)))
+			require.NoError(t, err)
+			resp.Body.Close()
+
+			if reject {
+				require.EqualValues(t, 400, resp.StatusCode)
+				require.Equal(t, 0, len(acc.GetTelegrafMetrics()))
+				require.Equal(t, fieldless+1, listener.metricsFieldless.Get())
+			} else {
+				require.EqualValues(t, 204, resp.StatusCode)
+				require.Equal(t, fieldless, listener.metricsFieldless.Get())
+			}
+		})
+	}
+}
+
+func TestMaxConnectionsPerIP(t *testing.T) {
+	listener := newTestListener()
+	listener.MaxConnectionsPerIP = 2
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	rejected := listener.connectionsRejected.Get()
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(listener.port))
+
+	var conns []net.Conn
+	for i := 0; i < 2; i++ {
+		conn, err := net.Dial("tcp", addr)
+		require.NoError(t, err)
+		conns = append(conns, conn)
+	}
+
+	// Connections over the limit are closed by the server without a reply.
+	for i := 0; i < 3; i++ {
+		conn, err := net.Dial("tcp", addr)
+		require.NoError(t, err)
+		_, err = conn.Write([]byte("GET /ping HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+		if err == nil {
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			_, err = conn.Read(make([]byte, 1))
+		}
+		require.Error(t, err)
+		conn.Close()
+	}
+	require.Equal(t, rejected+3, listener.connectionsRejected.Get())
+
+	// Once the connections are closed, the client may connect again and its
+	// entry is dropped.
+	for _, conn := range conns {
+		conn.Close()
+	}
+	require.Eventually(t, func() bool {
+		listener.connsMu.Lock()
+		defer listener.connsMu.Unlock()
+		return len(listener.ipConns) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	resp, err := http.Post(createURL(listener, "http", "/write", "db=mydb"), "", bytes.NewBuffer([]byte(testMsg)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+}
+
+func TestWriteInjectResponseDelay(t *testing.T) {
+	listener := newTestListener()
+	listener.InjectResponseDelay = internal.Duration{Duration: 500 * time.Millisecond}
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	start := time.Now()
+	resp, err := http.Post(createURL(listener, "http", "/write", "db=mydb"), "", bytes.NewBuffer([]byte(testMsg)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+	require.True(t, time.Since(start) >= 500*time.Millisecond)
+
+	// A canceled request ends the delay early.
+	listener.InjectResponseDelay = internal.Duration{Duration: time.Minute}
+	served := listener.writesServed.Get()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequest("POST", createURL(listener, "http", "/write", "db=mydb"), bytes.NewBuffer([]byte(testMsg)))
+	require.NoError(t, err)
+	_, err = http.DefaultClient.Do(req.WithContext(ctx))
+	require.Error(t, err)
+
+	require.Eventually(t, func() bool {
+		return listener.writesServed.Get() == served+1
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestWriteMaxDistinctTagKeysPerRequest(t *testing.T) {
+	listener := newTestListener()
+	listener.MaxDistinctTagKeysPerRequest = 3
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	overLimit := listener.metricsOverTagKeyLimit.Get()
+
+	// The third metric would add a fourth tag key, the others only reuse
+	// keys already seen.
+	msg := "cpu,a=1,b=2 x=1 0\ncpu,c=3 x=2 0\ncpu,a=1,d=4 x=3 0\ncpu,a=5,c=6 x=4 0\n"
+	resp, err := http.Post(createURL(listener, "http", "/write", ""), "", bytes.NewBuffer([]byte(msg)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, http.StatusBadRequest, resp.StatusCode)
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric("cpu",
+			map[string]string{"a": "1", "b": "2"},
+			map[string]interface{}{"x": 1.0},
+			time.Unix(0, 0)),
+		testutil.MustMetric("cpu",
+			map[string]string{"c": "3"},
+			map[string]interface{}{"x": 2.0},
+			time.Unix(0, 0)),
+		testutil.MustMetric("cpu",
+			map[string]string{"a": "5", "c": "6"},
+			map[string]interface{}{"x": 4.0},
+			time.Unix(0, 0)),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics())
+	require.Equal(t, overLimit+1, listener.metricsOverTagKeyLimit.Get())
+
+	// The limit applies to each request separately.
+	resp, err = http.Post(createURL(listener, "http", "/write", ""), "", bytes.NewBuffer([]byte("cpu,a=1,d=4 x=3 0\n")))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, http.StatusNoContent, resp.StatusCode)
+}
+
+func TestWriteTrackClockSkew(t *testing.T) {
+	listener := newTestListener()
+	listener.TrackClockSkew = true
+	listener.timeFunc = func() time.Time {
+		return time.Unix(100, 0)
+	}
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	// The point without a timestamp is not taken into account.
+	msg := "cpu x=1 90000000000\ncpu x=2 99000000000\ncpu x=3 110000000000\ncpu x=4\n"
+	resp, err := http.Post(createURL(listener, "http", "/write", ""), "", bytes.NewBuffer([]byte(msg)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+
+	require.NoError(t, listener.Gather(acc))
+	require.Equal(t, int64(-10*time.Second), listener.clockSkewMin.Get())
+	require.Equal(t, int64(10*time.Second), listener.clockSkewMax.Get())
+	require.Equal(t, int64(time.Second/3), listener.clockSkewAvg.Get())
+}
+
+func TestWriteFormPrecision(t *testing.T) {
+	listener := newTestListener()
+	listener.AcceptFormPrecision = true
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	form := url.Values{}
+	form.Set("precision", "s")
+	form.Set("data", "cpu value=1 1422568543\n")
+	resp, err := http.PostForm(createURL(listener, "http", "/write", ""), form)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+
+	// The query string takes precedence over the form.
+	form.Set("precision", "ns")
+	resp, err = http.PostForm(createURL(listener, "http", "/write", "precision=s"), form)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+
+	// Other bodies are not parsed as a form.
+	resp, err = http.Post(createURL(listener, "http", "/write", ""), "text/plain", bytes.NewBuffer([]byte("cpu value=3 1422568543\n")))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+
+	// Neither are URL encoded bodies without a data field, as curl sends
+	// them by default.
+	resp, err = http.Post(createURL(listener, "http", "/write", ""), "application/x-www-form-urlencoded", bytes.NewBuffer([]byte("cpu,host=a value=4 1422568543\n")))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric("cpu",
+			map[string]string{},
+			map[string]interface{}{"value": 1.0},
+			time.Unix(1422568543, 0)),
+		testutil.MustMetric("cpu",
+			map[string]string{},
+			map[string]interface{}{"value": 1.0},
+			time.Unix(1422568543, 0)),
+		testutil.MustMetric("cpu",
+			map[string]string{},
+			map[string]interface{}{"value": 3.0},
+			time.Unix(0, 1422568543)),
+		testutil.MustMetric("cpu",
+			map[string]string{"host": "a"},
+			map[string]interface{}{"value": 4.0},
+			time.Unix(0, 1422568543)),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics())
+}
+
+func TestQueryMaxBodySize(t *testing.T) {
+	listener := newTestListener()
+	listener.QueryMaxBodySize = internal.Size{Size: 64}
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	form := url.Values{}
+	form.Set("q", "show databases")
+	resp, err := http.PostForm(createURL(listener, "http", "/query", ""), form)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 200, resp.StatusCode)
+
+	form.Set("q", "select * from cpu where host = '"+strings.Repeat("a", 64)+"'")
+	resp, err = http.PostForm(createURL(listener, "http", "/query", ""), form)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+
+	// Without a content length the body is cut off at the limit.
+	resp, err = http.Post(createURL(listener, "http", "/query", ""), "", ioutil.NopCloser(strings.NewReader(strings.Repeat("a", 65))))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+}
+
+func TestQueryMaxBodySizeEmulated(t *testing.T) {
+	listener := newTestListener()
+	listener.QueryMaxBodySize = internal.Size{Size: 64}
+	listener.EmulateQueryStatements = true
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	// The form is parsed for the query, failing at the limit.
+	form := url.Values{}
+	form.Set("q", "select * from cpu where host = '"+strings.Repeat("a", 64)+"'")
+	resp, err := http.Post(createURL(listener, "http", "/query", ""), "application/x-www-form-urlencoded", ioutil.NopCloser(strings.NewReader(form.Encode())))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+
+	// A malformed form is still a bad request.
+	resp, err = http.Post(createURL(listener, "http", "/query", ""), "application/x-www-form-urlencoded", strings.NewReader("q=%zz"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestWriteDetectGzipByMagic(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write([]byte(testMsg))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+
+	for _, detect := range []bool{false, true} {
+		t.Run(strconv.FormatBool(detect), func(t *testing.T) {
+			listener := newTestListener()
+			listener.DetectGzipByMagic = detect
+
+			acc := &testutil.Accumulator{}
+			require.NoError(t, listener.Init())
+			require.NoError(t, listener.Start(acc))
+			defer listener.Stop()
+
+			resp, err := http.Post(createURL(listener, "http", "/write", ""), "", bytes.NewReader(buf.Bytes()))
+			require.NoError(t, err)
+			resp.Body.Close()
+			if !detect {
+				require.EqualValues(t, 400, resp.StatusCode)
+				return
+			}
+			require.EqualValues(t, 204, resp.StatusCode)
+
+			// Line protocol is left as is.
+			resp, err = http.Post(createURL(listener, "http", "/write", ""), "", bytes.NewBuffer([]byte(testMsg)))
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.EqualValues(t, 204, resp.StatusCode)
+
+			acc.Wait(2)
+			for _, m := range acc.GetTelegrafMetrics() {
+				require.Equal(t, "cpu_load_short", m.Name())
+				require.Equal(t, map[string]string{"host": "server01"}, m.Tags())
+			}
+		})
+	}
+}
+
+func TestWriteMaxConcurrentParses(t *testing.T) {
+	listener := newTestListener()
+	listener.MaxConcurrentParses = 2
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	// Take the place of writes being parsed.
+	listener.parseSem <- struct{}{}
+	listener.parseSem <- struct{}{}
+
+	resp, err := http.Post(createURL(listener, "http", "/write", ""), "", bytes.NewBuffer([]byte(testMsg)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, http.StatusServiceUnavailable, resp.StatusCode)
+	require.Equal(t, "1", resp.Header.Get("Retry-After"))
+
+	<-listener.parseSem
+	resp, err = http.Post(createURL(listener, "http", "/write", ""), "", bytes.NewBuffer([]byte(testMsg)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+	require.Len(t, listener.parseSem, 1)
+}
+
+func TestWriteAutoDetectPrecision(t *testing.T) {
+	tests := []struct {
+		name      string
+		precision string
+		input     string
+		expected  time.Time
+	}{
+		{
+			name:      "matching precision",
+			precision: "s",
+			input:     "cpu value=1 1422568543\n",
+			expected:  time.Unix(1422568543, 0),
+		},
+		{
+			name:      "milliseconds with second precision",
+			precision: "s",
+			input:     "cpu value=1 1422568543702\n",
+			expected:  time.Unix(0, 1422568543702*int64(time.Millisecond)),
+		},
+		{
+			name:      "seconds without precision",
+			precision: "",
+			input:     "cpu value=1 1422568543\n",
+			expected:  time.Unix(1422568543, 0),
+		},
+		{
+			name:      "nanoseconds with millisecond precision",
+			precision: "ms",
+			input:     "cpu value=1 1422568543702900257\n",
+			expected:  time.Unix(0, 1422568543702900257),
+		},
+		{
+			name:      "no plausible precision",
+			precision: "s",
+			input:     "cpu value=1 0\n",
+			expected:  time.Unix(0, 0),
+		},
+		{
+			name:      "no timestamp",
+			precision: "s",
+			input:     "cpu value=1\n",
+			expected:  time.Unix(42, 0),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listener := newTestListener()
+			listener.AutoDetectPrecision = true
+			listener.timeFunc = func() time.Time {
+				return time.Unix(42, 123456789)
+			}
+
+			acc := &testutil.Accumulator{}
+			require.NoError(t, listener.Init())
+			require.NoError(t, listener.Start(acc))
+			defer listener.Stop()
+
+			query := ""
+			if tt.precision != "" {
+				query = "precision=" + tt.precision
+			}
+			resp, err := http.Post(createURL(listener, "http", "/write", query), "", bytes.NewBuffer([]byte(tt.input)))
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.EqualValues(t, 204, resp.StatusCode)
+
+			metrics := acc.GetTelegrafMetrics()
+			require.Len(t, metrics, 1)
+			require.Equal(t, tt.expected.UnixNano(), metrics[0].Time().UnixNano())
+		})
+	}
+}
+
+func TestWriteAccumulateOnReadError(t *testing.T) {
+	for _, accumulate := range []bool{false, true} {
+		t.Run(strconv.FormatBool(accumulate), func(t *testing.T) {
+			listener := newTestListener()
+			listener.AccumulateOnReadError = accumulate
+
+			acc := &testutil.Accumulator{}
+			require.NoError(t, listener.Init())
+			require.NoError(t, listener.Start(acc))
+			defer listener.Stop()
+
+			// Send only part of the announced body, ending in an incomplete
+			// line, before the client goes away.
+			conn, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(listener.port)))
+			require.NoError(t, err)
+			defer conn.Close()
+			body := testMsgs + "cpu_load_short,host=server07 value=1"
+			_, err = fmt.Fprintf(conn, "POST /write HTTP/1.1\r\nHost: localhost\r\nContent-Length: %d\r\n\r\n%s", len(body)+100, body)
+			require.NoError(t, err)
+			require.NoError(t, conn.(*net.TCPConn).CloseWrite())
+
+			resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.EqualValues(t, 400, resp.StatusCode)
+			if accumulate {
+				require.Contains(t, resp.Header.Get("X-Influxdb-Error"), "partial write: reading the body failed")
+				require.Len(t, acc.GetTelegrafMetrics(), 5)
+			} else {
+				require.Equal(t, "unexpected EOF", resp.Header.Get("X-Influxdb-Error"))
+			}
+		})
+	}
+}
+
+func TestWriteCompressionStats(t *testing.T) {
+	listener := newTestListener()
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	gzipped := listener.requestsGzip.Get()
+	plaintext := listener.requestsPlaintext.Get()
+	compressedBytes := listener.compressedBytes.Get()
+	decompressedBytes := listener.decompressedBytes.Get()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write([]byte(testMsgs))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	compressedLen := int64(buf.Len())
+
+	req, err := http.NewRequest("POST", createURL(listener, "http", "/write", ""), &buf)
+	require.NoError(t, err)
+	req.Header.Set("Content-Encoding", "gzip")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+
+	for i := 0; i < 2; i++ {
+		resp, err = http.Post(createURL(listener, "http", "/write", ""), "", bytes.NewBuffer([]byte(testMsg)))
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.EqualValues(t, 204, resp.StatusCode)
+	}
+
+	require.Equal(t, gzipped+1, listener.requestsGzip.Get())
+	require.Equal(t, plaintext+2, listener.requestsPlaintext.Get())
+	require.Equal(t, compressedBytes+compressedLen, listener.compressedBytes.Get())
+	require.Equal(t, decompressedBytes+int64(len(testMsgs)), listener.decompressedBytes.Get())
+}
+
+func TestDatabaseWrites(t *testing.T) {
+	listener := newTestListener()
+	listener.MaxTrackedDatabases = 2
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	for _, db := range []string{"db1", "db2", "db1", "db3", "db4", ""} {
+		resp, err := http.Post(createURL(listener, "http", "/write", "db="+db), "", bytes.NewBuffer([]byte(testMsg)))
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.EqualValues(t, 204, resp.StatusCode)
+	}
+
+	listener.dbStatsMu.Lock()
+	defer listener.dbStatsMu.Unlock()
+	require.Len(t, listener.dbStats, 3)
+	require.Equal(t, int64(2), listener.dbStats["db1"].Get())
+	require.Equal(t, int64(1), listener.dbStats["db2"].Get())
+	require.Equal(t, int64(2), listener.dbStats["other"].Get())
+}
+
+func TestTLSSNICerts(t *testing.T) {
+	listener := newTestListener()
+	listener.TLSCert = pki.ServerCertPath()
+	listener.TLSKey = pki.ServerKeyPath()
+	listener.TLSSNICerts = map[string]SNICert{
+		"Other.Example.com": {TLSCert: pki.ClientCertPath(), TLSKey: pki.ClientKeyPath()},
+	}
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	serverCert, err := tls.LoadX509KeyPair(pki.ServerCertPath(), pki.ServerKeyPath())
+	require.NoError(t, err)
+	otherCert, err := tls.LoadX509KeyPair(pki.ClientCertPath(), pki.ClientKeyPath())
+	require.NoError(t, err)
+
+	tests := []struct {
+		serverName string
+		expected   []byte
+	}{
+		{serverName: "other.example.com", expected: otherCert.Certificate[0]},
+		{serverName: "localhost", expected: serverCert.Certificate[0]},
+		{serverName: "", expected: serverCert.Certificate[0]},
+	}
+	for _, tt := range tests {
+		conn, err := tls.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(listener.port)), &tls.Config{
+			ServerName:         tt.serverName,
+			InsecureSkipVerify: true,
+		})
+		require.NoError(t, err)
+		certs := conn.ConnectionState().PeerCertificates
+		conn.Close()
+		require.Equal(t, tt.expected, certs[0].Raw, tt.serverName)
+	}
+}
+
+func TestInitInvalidSNICert(t *testing.T) {
+	listener := newTestListener()
+	listener.TLSCert = pki.ServerCertPath()
+	listener.TLSKey = pki.ServerKeyPath()
+	listener.TLSSNICerts = map[string]SNICert{
+		"other.example.com": {TLSCert: pki.ClientCertPath(), TLSKey: "/nonexistent/key.pem"},
+	}
+	require.Error(t, listener.Init())
+}
+
+func TestOptions(t *testing.T) {
+	listener := newTestAuthListener()
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	tests := []struct {
+		path    string
+		status  int
+		methods string
+	}{
+		{path: "/write", status: http.StatusNoContent, methods: "POST, OPTIONS"},
+		{path: "/query", status: http.StatusNoContent, methods: "GET, POST, OPTIONS"},
+		{path: "/ping", status: http.StatusNoContent, methods: "GET, HEAD, OPTIONS"},
+		{path: "/write/csv", status: http.StatusUnauthorized, methods: ""},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest("OPTIONS", createURL(listener, "http", tt.path, ""), nil)
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.EqualValues(t, tt.status, resp.StatusCode, tt.path)
+		require.Equal(t, tt.methods, resp.Header.Get("Allow"), tt.path)
+	}
+}
+
+func TestWriteStrictMethods(t *testing.T) {
+	for _, strict := range []bool{false, true} {
+		t.Run(strconv.FormatBool(strict), func(t *testing.T) {
+			listener := newTestListener()
+			listener.StrictMethods = strict
+
+			acc := &testutil.Accumulator{}
+			require.NoError(t, listener.Init())
+			require.NoError(t, listener.Start(acc))
+			defer listener.Stop()
+
+			resp, err := http.Post(createURL(listener, "http", "/write", ""), "", bytes.NewBuffer([]byte(testMsg)))
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.EqualValues(t, 204, resp.StatusCode)
+
+			resp, err = http.Get(createURL(listener, "http", "/write", ""))
+			require.NoError(t, err)
+			resp.Body.Close()
+			if strict {
+				require.EqualValues(t, http.StatusMethodNotAllowed, resp.StatusCode)
+				require.Equal(t, "POST", resp.Header.Get("Allow"))
+			} else {
+				require.EqualValues(t, 204, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestWriteDefaultPrecision(t *testing.T) {
+	listener := newTestListener()
+	listener.DefaultPrecision = "ms"
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	resp, err := http.Post(createURL(listener, "http", "/write", ""), "", bytes.NewBuffer([]byte("cpu value=1 1422568543702\n")))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+
+	// The query parameter overrides the default.
+	resp, err = http.Post(createURL(listener, "http", "/write", "precision=s"), "", bytes.NewBuffer([]byte("cpu value=2 1422568543\n")))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric("cpu",
+			map[string]string{},
+			map[string]interface{}{"value": 1.0},
+			time.Unix(0, 1422568543702*int64(time.Millisecond))),
+		testutil.MustMetric("cpu",
+			map[string]string{},
+			map[string]interface{}{"value": 2.0},
+			time.Unix(1422568543, 0)),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics())
+}
+
+func TestInitInvalidDefaultPrecision(t *testing.T) {
+	listener := newTestListener()
+	listener.DefaultPrecision = "d"
+	require.Error(t, listener.Init())
+}
+
+func TestWritePointCountHeader(t *testing.T) {
+	listener := newTestListener()
+	listener.PointCountHeader = true
+	listener.MaxTagsPerMetric = 1
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	resp, err := http.Post(createURL(listener, "http", "/write", ""), "", bytes.NewBuffer([]byte(testMsgs)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+	require.Equal(t, "5", resp.Header.Get("X-Points-Written"))
+	require.Len(t, acc.GetTelegrafMetrics(), 5)
+
+	// Partial writes are not acknowledged.
+	resp, err = http.Post(createURL(listener, "http", "/write", ""), "", bytes.NewBuffer([]byte("cpu,a=1,b=2 x=1 0\ncpu,a=1 x=2 0\n")))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 400, resp.StatusCode)
+	require.Equal(t, "", resp.Header.Get("X-Points-Written"))
+}
+
+func TestWriteDatabaseFieldPrefix(t *testing.T) {
+	listener := newTestListener()
+	listener.DatabaseFieldPrefix = map[string]string{
+		"x": "x_",
+	}
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	msg := "cpu,host=a usage=1,idle=2 0\n"
+	for _, db := range []string{"x", "y"} {
+		resp, err := http.Post(createURL(listener, "http", "/write", "db="+db), "", bytes.NewBuffer([]byte(msg)))
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.EqualValues(t, 204, resp.StatusCode)
+	}
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric("cpu",
+			map[string]string{"host": "a"},
+			map[string]interface{}{"x_usage": 1.0, "x_idle": 2.0},
+			time.Unix(0, 0)),
+		testutil.MustMetric("cpu",
+			map[string]string{"host": "a"},
+			map[string]interface{}{"usage": 1.0, "idle": 2.0},
+			time.Unix(0, 0)),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics())
+}
+
+func TestWriteRequireValidUTF8(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     string
+		status   int
+		expected []telegraf.Metric
+	}{
+		{
+			name:   "drop",
+			status: http.StatusBadRequest,
+			expected: []telegraf.Metric{
+				testutil.MustMetric("cpu",
+					map[string]string{"host": "a"},
+					map[string]interface{}{"value": 2.0},
+					time.Unix(0, 0)),
+			},
+		},
+		{
+			name:   "sanitize",
+			mode:   "sanitize",
+			status: http.StatusNoContent,
+			expected: []telegraf.Metric{
+				testutil.MustMetric("cpu",
+					map[string]string{"host": "a\ufffd"},
+					map[string]interface{}{"value": 1.0, "msg": "b\ufffd("},
+					time.Unix(0, 0)),
+				testutil.MustMetric("cpu",
+					map[string]string{"host": "a"},
+					map[string]interface{}{"value": 2.0},
+					time.Unix(0, 0)),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listener := newTestListener()
+			listener.RequireValidUTF8 = true
+			listener.InvalidUTF8Mode = tt.mode
+
+			acc := &testutil.Accumulator{}
+			require.NoError(t, listener.Init())
+			require.NoError(t, listener.Start(acc))
+			defer listener.Stop()
+
+			invalid := listener.metricsInvalidUTF8.Get()
+
+			msg := "cpu,host=a\xff value=1,msg=\"b\xc3\x28\" 0\ncpu,host=a value=2 0\n"
+			resp, err := http.Post(createURL(listener, "http", "/write", ""), "", bytes.NewBuffer([]byte(msg)))
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.EqualValues(t, tt.status, resp.StatusCode)
+
+			testutil.RequireMetricsEqual(t, tt.expected, acc.GetTelegrafMetrics())
+			require.Equal(t, invalid+1, listener.metricsInvalidUTF8.Get())
+		})
+	}
+}
+
+func TestInitInvalidUTF8Mode(t *testing.T) {
+	listener := newTestListener()
+	listener.InvalidUTF8Mode = "escape"
+	require.Error(t, listener.Init())
+}
+
+func TestWriteTimestampRound(t *testing.T) {
+	listener := newTestListener()
+	listener.TimestampRound = internal.Duration{Duration: time.Second}
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	resp, err := http.Post(createURL(listener, "http", "/write", ""), "", bytes.NewBuffer([]byte(testMsg)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+
+	metrics := acc.GetTelegrafMetrics()
+	require.Len(t, metrics, 1)
+	require.Equal(t, time.Unix(1422568543, 0).UnixNano(), metrics[0].Time().UnixNano())
+}
+
+func TestPingContentType(t *testing.T) {
+	listener := newTestListener()
+	listener.PingContentType = "text/plain"
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	resp, err := http.Get(createURL(listener, "http", "/ping", ""))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+	require.Equal(t, "text/plain", resp.Header.Get("Content-Type"))
+
+	resp, err = http.Get(createURL(listener, "http", "/ping", "verbose=true"))
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 200, resp.StatusCode)
+	require.Equal(t, "text/plain", resp.Header.Get("Content-Type"))
+	require.Equal(t, `{"version":"1.0"}`, string(body))
+}
+
+func TestWriteStrictQueryParams(t *testing.T) {
+	listener := newTestListener()
+	listener.StrictQueryParams = true
+	listener.QueryParamTags = map[string]string{"host": "source"}
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	resp, err := http.Post(createURL(listener, "http", "/write", "db=mydb&rp=autogen&precision=s&host=a"), "", bytes.NewBuffer([]byte(testMsgNoNewline)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+
+	resp, err = http.Post(createURL(listener, "http", "/write", "db=mydb&bogus=1"), "", bytes.NewBuffer([]byte(testMsgNoNewline)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 400, resp.StatusCode)
+	require.Equal(t, `unknown query parameter "bogus"`, resp.Header.Get("X-Influxdb-Error"))
+
+	require.Len(t, acc.GetTelegrafMetrics(), 1)
+}
+
+func TestWriteFieldTypes(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     string
+		status   int
+		rejected int64
+		expected []telegraf.Metric
+	}{
+		{
+			name:     "coerce",
+			status:   http.StatusBadRequest,
+			rejected: 1,
+			expected: []telegraf.Metric{
+				testutil.MustMetric("weather",
+					map[string]string{},
+					map[string]interface{}{"humidity": int64(42), "station": "12"},
+					time.Unix(0, 0)),
+				testutil.MustMetric("weather",
+					map[string]string{},
+					map[string]interface{}{"humidity": int64(43), "station": "13"},
+					time.Unix(0, 0)),
+			},
+		},
+		{
+			name:     "drop",
+			mode:     "drop",
+			status:   http.StatusBadRequest,
+			rejected: 2,
+			expected: []telegraf.Metric{
+				testutil.MustMetric("weather",
+					map[string]string{},
+					map[string]interface{}{"humidity": int64(43), "station": "13"},
+					time.Unix(0, 0)),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listener := newTestListener()
+			listener.FieldTypes = map[string]string{
+				"weather.humidity": "integer",
+				"weather.station":  "string",
+			}
+			listener.FieldTypeMode = tt.mode
+
+			acc := &testutil.Accumulator{}
+			require.NoError(t, listener.Init())
+			require.NoError(t, listener.Start(acc))
+			defer listener.Stop()
+
+			rejected := listener.metricsWrongFieldType.Get()
+
+			msg := "weather humidity=42,station=12i 0\n" +
+				"weather humidity=43i,station=\"13\" 0\n" +
+				"weather humidity=42.5,station=\"14\" 0\n"
+			resp, err := http.Post(createURL(listener, "http", "/write", ""), "", bytes.NewBuffer([]byte(msg)))
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.EqualValues(t, tt.status, resp.StatusCode)
+
+			testutil.RequireMetricsEqual(t, tt.expected, acc.GetTelegrafMetrics())
+			require.Equal(t, rejected+tt.rejected, listener.metricsWrongFieldType.Get())
+		})
+	}
+}
+
+func TestInitInvalidFieldTypes(t *testing.T) {
+	listener := newTestListener()
+	listener.FieldTypes = map[string]string{"weather.humidity": "int"}
+	require.Error(t, listener.Init())
+
+	listener = newTestListener()
+	listener.FieldTypeMode = "ignore"
+	require.Error(t, listener.Init())
+}
+
+// recordingLogger keeps the warnings logged.
+type recordingLogger struct {
+	testutil.Logger
+	mu       sync.Mutex
+	warnings []string
+	debugs   []string
+}
+
+func (l *recordingLogger) Warnf(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.warnings = append(l.warnings, fmt.Sprintf(format, args...))
+}
+
+func (l *recordingLogger) Warnings() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.warnings...)
+}
+
+func (l *recordingLogger) Debugf(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.debugs = append(l.debugs, fmt.Sprintf(format, args...))
+}
+
+func (l *recordingLogger) Debugs() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.debugs...)
+}
+
+func TestWriteSlowWriteThreshold(t *testing.T) {
+	log := &recordingLogger{}
+	listener := newTestListener()
+	listener.Log = log
+	listener.SlowWriteThreshold = internal.Duration{Duration: 10 * time.Millisecond}
+	listener.InjectResponseDelay = internal.Duration{Duration: 50 * time.Millisecond}
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	resp, err := http.Post(createURL(listener, "http", "/write", ""), "", bytes.NewBuffer([]byte(testMsg)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+
+	require.Eventually(t, func() bool {
+		return len(log.Warnings()) == 1
+	}, time.Second, 10*time.Millisecond)
+	require.Contains(t, log.Warnings()[0], "Slow write to /write from ")
+	require.Contains(t, log.Warnings()[0], fmt.Sprintf(": %d bytes in ", len(testMsg)))
+}
+
+func TestWriteMaxConcurrentDecompressions(t *testing.T) {
+	listener := newTestListener()
+	listener.MaxConcurrentDecompressions = 1
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write([]byte(testMsg))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+
+	postGzip := func() int {
+		req, err := http.NewRequest("POST", createURL(listener, "http", "/write", ""), bytes.NewReader(buf.Bytes()))
+		require.NoError(t, err)
+		req.Header.Set("Content-Encoding", "gzip")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// Hold the only token as if a gzipped write was being decompressed.
+	listener.decompressSem <- struct{}{}
+	require.Equal(t, http.StatusServiceUnavailable, postGzip())
+
+	resp, err := http.Post(createURL(listener, "http", "/write", ""), "", bytes.NewBuffer([]byte(testMsg)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+
+	<-listener.decompressSem
+	require.Equal(t, http.StatusNoContent, postGzip())
+	require.Len(t, listener.decompressSem, 0)
+	require.Equal(t, int64(0), listener.decompressionsActive.Get())
+	require.Len(t, acc.GetTelegrafMetrics(), 2)
+}
+
+func TestMeasurementWrites(t *testing.T) {
+	listener := newTestListener()
+	listener.PerMeasurementStats = true
+	listener.MaxTrackedMeasurements = 2
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	msg := "cpu value=1 0\nmem value=2 0\ncpu value=3 0\ndisk value=4 0\nnet value=5 0\n"
+	resp, err := http.Post(createURL(listener, "http", "/write", ""), "", bytes.NewBuffer([]byte(msg)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+
+	listener.measurementStatsMu.Lock()
+	defer listener.measurementStatsMu.Unlock()
+	require.Len(t, listener.measurementStats, 3)
+	require.Equal(t, int64(2), listener.measurementStats["cpu"].Get())
+	require.Equal(t, int64(1), listener.measurementStats["mem"].Get())
+	require.Equal(t, int64(2), listener.measurementStats["other"].Get())
+}
+
+func TestTLSHandshakeErrors(t *testing.T) {
+	listener := newTestSecureListener()
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	handshakeErrors := listener.tlsHandshakeErrors.Get()
+
+	conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", listener.port))
+	require.NoError(t, err)
+	_, err = conn.Write([]byte("\x00\x00\x00\x00\x00\x00\x00\x00"))
+	require.NoError(t, err)
+	conn.Close()
+
+	require.Eventually(t, func() bool {
+		return listener.tlsHandshakeErrors.Get() == handshakeErrors+1
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestWriteNDJSON(t *testing.T) {
+	listener := newTestListener()
+	listener.EnableNDJSONEndpoint = true
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	msg := `{"name":"cpu","tags":{"host":"a"},"fields":{"value":42,"usage":0.5,"ok":true,"state":"up"},"timestamp":1422568543}` + "\n\n" +
+		`{"name":"mem","fields":{"free":1024},"timestamp":1422568544}`
+	resp, err := http.Post(createURL(listener, "http", "/write/ndjson", "precision=s"), "", bytes.NewBuffer([]byte(msg)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric("cpu",
+			map[string]string{"host": "a"},
+			map[string]interface{}{"value": int64(42), "usage": 0.5, "ok": true, "state": "up"},
+			time.Unix(1422568543, 0)),
+		testutil.MustMetric("mem",
+			map[string]string{},
+			map[string]interface{}{"free": int64(1024)},
+			time.Unix(1422568544, 0)),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics())
+}
+
+func TestWriteNDJSONPartial(t *testing.T) {
+	listener := newTestListener()
+	listener.EnableNDJSONEndpoint = true
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	msg := `{"name":"cpu","fields":{"value":1},"timestamp":0}` + "\n" +
+		`{"name":"cpu","fields":{"value":` + "\n" +
+		`{"name":"cpu","fields":{}}` + "\n" +
+		`{"name":"cpu","fields":{"value":2},"timestamp":0}` + "\n"
+	resp, err := http.Post(createURL(listener, "http", "/write/ndjson", ""), "", bytes.NewBuffer([]byte(msg)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 400, resp.StatusCode)
+	require.Contains(t, resp.Header.Get("X-Influxdb-Error"), "line 2: ")
+	require.Contains(t, resp.Header.Get("X-Influxdb-Error"), "(and 1 other parse error)")
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric("cpu",
+			map[string]string{},
+			map[string]interface{}{"value": int64(1)},
+			time.Unix(0, 0)),
+		testutil.MustMetric("cpu",
+			map[string]string{},
+			map[string]interface{}{"value": int64(2)},
+			time.Unix(0, 0)),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics())
+}
+
+func TestWriteNDJSONTimestampOverflow(t *testing.T) {
+	listener := newTestListener()
+	listener.EnableNDJSONEndpoint = true
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	msg := `{"name":"cpu","fields":{"value":1},"timestamp":1422568543702900257}` + "\n" +
+		`{"name":"cpu","fields":{"value":2},"timestamp":1422568543}` + "\n"
+	resp, err := http.Post(createURL(listener, "http", "/write/ndjson", "precision=s"), "", bytes.NewBuffer([]byte(msg)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 400, resp.StatusCode)
+	require.Contains(t, resp.Header.Get("X-Influxdb-Error"), "line 1: ")
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric("cpu",
+			map[string]string{},
+			map[string]interface{}{"value": int64(2)},
+			time.Unix(1422568543, 0)),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics())
+}
+
+func TestWriteBodyReadTimeout(t *testing.T) {
+	listener := newTestListener()
+	listener.BodyReadTimeout = internal.Duration{Duration: 100 * time.Millisecond}
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", listener.port))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// Chunks arriving within the timeout keep the body read going.
+	msg := testMsgs + testMsgs
+	_, err = fmt.Fprintf(conn, "POST /write HTTP/1.1\r\nHost: localhost\r\nContent-Length: %d\r\n\r\n", len(msg)+len(testMsg))
+	require.NoError(t, err)
+	for _, chunk := range []string{testMsgs, testMsgs} {
+		_, err = conn.Write([]byte(chunk))
+		require.NoError(t, err)
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	// Then the client stalls.
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, http.StatusRequestTimeout, resp.StatusCode)
+}
+
+func TestRootResponse(t *testing.T) {
+	listener := newTestListener()
+	listener.RootResponse = RootResponse{Status: http.StatusOK, Body: "OK"}
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	resp, err := http.Get(createURL(listener, "http", "/", ""))
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 200, resp.StatusCode)
+	require.Equal(t, "OK", string(body))
+
+	resp, err = http.Get(createURL(listener, "http", "/unknown", ""))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 404, resp.StatusCode)
+}
+
+func TestInitInvalidRootResponse(t *testing.T) {
+	listener := newTestListener()
+	listener.RootResponse = RootResponse{Status: 42}
+	require.Error(t, listener.Init())
+}
+
+func TestHeartbeat(t *testing.T) {
+	listener := newTestListener()
+	listener.HeartbeatInterval = internal.Duration{Duration: 10 * time.Millisecond}
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+
+	acc.Wait(2)
+	listener.Stop()
+
+	for _, m := range acc.GetTelegrafMetrics() {
+		require.Equal(t, "telegraf_influxdb_listener_heartbeat", m.Name())
+		require.Equal(t, listener.ServiceAddress, m.Tags()["address"])
+		uptime, ok := m.GetField("uptime_ns")
+		require.True(t, ok)
+		require.True(t, uptime.(int64) > 0)
+	}
+
+	// No heartbeats once stopped.
+	n := acc.NMetrics()
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, n, acc.NMetrics())
+}
+
+func TestWritePrecisionHeader(t *testing.T) {
+	listener := newTestListener()
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	post := func(query, precision string) int {
+		req, err := http.NewRequest("POST", createURL(listener, "http", "/write", query), bytes.NewBuffer([]byte("cpu value=1 1422568543\n")))
+		require.NoError(t, err)
+		req.Header.Set("X-Influx-Precision", precision)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	require.Equal(t, http.StatusNoContent, post("", "s"))
+	// The query parameter takes precedence.
+	require.Equal(t, http.StatusNoContent, post("precision=ms", "s"))
+	require.Equal(t, http.StatusBadRequest, post("", "seconds"))
+
+	metrics := acc.GetTelegrafMetrics()
+	require.Len(t, metrics, 2)
+	require.Equal(t, time.Unix(1422568543, 0).UnixNano(), metrics[0].Time().UnixNano())
+	require.Equal(t, time.Unix(1422568, 543000000).UnixNano(), metrics[1].Time().UnixNano())
+}
+
+func TestWriteUserAgents(t *testing.T) {
+	tests := []struct {
+		name     string
+		blocked  []string
+		required string
+		agent    string
+		status   int
+	}{
+		{
+			name:    "blocked",
+			blocked: []string{"Telegraf/1.13.0*", "badclient/*"},
+			agent:   "badclient/2.0",
+			status:  http.StatusForbidden,
+		},
+		{
+			name:    "not blocked",
+			blocked: []string{"Telegraf/1.13.0*", "badclient/*"},
+			agent:   "Telegraf/1.14.0",
+			status:  http.StatusNoContent,
+		},
+		{
+			name:     "required",
+			required: "Telegraf/*",
+			agent:    "Telegraf/1.14.0",
+			status:   http.StatusNoContent,
+		},
+		{
+			name:     "not required",
+			required: "Telegraf/*",
+			agent:    "curl/7.68.0",
+			status:   http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listener := newTestListener()
+			listener.BlockedUserAgents = tt.blocked
+			listener.RequiredUserAgent = tt.required
+
+			acc := &testutil.Accumulator{}
+			require.NoError(t, listener.Init())
+			require.NoError(t, listener.Start(acc))
+			defer listener.Stop()
+
+			rejected := listener.userAgentsRejected.Get()
+
+			req, err := http.NewRequest("POST", createURL(listener, "http", "/write", ""), bytes.NewBuffer([]byte(testMsg)))
+			require.NoError(t, err)
+			req.Header.Set("User-Agent", tt.agent)
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.EqualValues(t, tt.status, resp.StatusCode)
+
+			if tt.status == http.StatusForbidden {
+				require.Contains(t, resp.Header.Get("X-Influxdb-Error"), "please upgrade")
+				require.Equal(t, rejected+1, listener.userAgentsRejected.Get())
+				require.Len(t, acc.GetTelegrafMetrics(), 0)
+			} else {
+				require.Equal(t, rejected, listener.userAgentsRejected.Get())
+				require.Len(t, acc.GetTelegrafMetrics(), 1)
+			}
+		})
+	}
+}
+
+func TestWriteTagsToFields(t *testing.T) {
+	listener := newTestListener()
+	listener.TagsToFields = []string{"value", "ratio", "state"}
+	listener.FieldsToTags = []string{"region", "zone"}
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	msg := "cpu,host=a,value=5,ratio=0.5,state=up usage=1,region=\"us-west\",zone=3i 0\n"
+	resp, err := http.Post(createURL(listener, "http", "/write", ""), "", bytes.NewBuffer([]byte(msg)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric("cpu",
+			map[string]string{"host": "a", "region": "us-west", "zone": "3"},
+			map[string]interface{}{"usage": 1.0, "value": int64(5), "ratio": 0.5, "state": "up"},
+			time.Unix(0, 0)),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics())
+}
+
+func TestWriteRequireTimestamp(t *testing.T) {
+	listener := newTestListener()
+	listener.RequireTimestamp = true
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	missing := listener.metricsNoTimestamp.Get()
+
+	msg := "cpu value=1 1422568543702900257\ncpu value=2\ncpu value=3 1422568543702900258\n"
+	resp, err := http.Post(createURL(listener, "http", "/write", ""), "", bytes.NewBuffer([]byte(msg)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 400, resp.StatusCode)
+	require.Equal(t, `metric "cpu" has no timestamp`, resp.Header.Get("X-Influxdb-Error"))
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric("cpu",
+			map[string]string{},
+			map[string]interface{}{"value": 1.0},
+			time.Unix(0, 1422568543702900257)),
+		testutil.MustMetric("cpu",
+			map[string]string{},
+			map[string]interface{}{"value": 3.0},
+			time.Unix(0, 1422568543702900258)),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics())
+	require.Equal(t, missing+1, listener.metricsNoTimestamp.Get())
+}
+
+func TestWriteGlobalRateLimit(t *testing.T) {
+	listener := newTestListener()
+	listener.GlobalRateLimit = 2
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	limited := listener.writesRateLimited.Get()
+
+	var statuses []int
+	for i := 0; i < 3; i++ {
+		resp, err := http.Post(createURL(listener, "http", "/write", ""), "", bytes.NewBuffer([]byte(testMsg)))
+		require.NoError(t, err)
+		resp.Body.Close()
+		statuses = append(statuses, resp.StatusCode)
+		if resp.StatusCode == http.StatusTooManyRequests {
+			require.Equal(t, "1", resp.Header.Get("Retry-After"))
+		}
+	}
+	require.Equal(t, []int{204, 204, 429}, statuses)
+	require.Equal(t, limited+1, listener.writesRateLimited.Get())
+}
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := newRateLimiter(2, now)
+
+	ok, _ := l.allow(now)
+	require.True(t, ok)
+	ok, _ = l.allow(now)
+	require.True(t, ok)
+	ok, wait := l.allow(now)
+	require.False(t, ok)
+	require.Equal(t, 500*time.Millisecond, wait)
+
+	ok, _ = l.allow(now.Add(500 * time.Millisecond))
+	require.True(t, ok)
+}
+
+func TestWriteDecompressionTime(t *testing.T) {
+	listener := newTestListener()
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	elapsed := listener.decompressionTime.Get()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	for i := 0; i < 1000; i++ {
+		_, err := gz.Write([]byte(testMsgs))
+		require.NoError(t, err)
+	}
+	require.NoError(t, gz.Close())
+
+	req, err := http.NewRequest("POST", createURL(listener, "http", "/write", ""), &buf)
+	require.NoError(t, err)
+	req.Header.Set("Content-Encoding", "gzip")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+
+	require.True(t, listener.decompressionTime.Get() > elapsed)
+}
+
+func TestQueryEmulateStatements(t *testing.T) {
+	listener := newTestListener()
+	listener.EmulateQueryStatements = true
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	query := func(resp *http.Response, err error) string {
+		require.NoError(t, err)
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.EqualValues(t, 200, resp.StatusCode)
+		return string(body)
+	}
+
+	require.Equal(t, `{"results":[{"statement_id":0}]}`,
+		query(http.Post(createURL(listener, "http", "/query", "q=CREATE+DATABASE+%22mydb%22"), "", nil)))
+	require.Equal(t, `{"results":[{"statement_id":0,"series":[{"name":"databases","columns":["name"]}]}]}`,
+		query(http.Get(createURL(listener, "http", "/query", "q=show+databases"))))
+	require.Equal(t, `{"results":[{"statement_id":0},{"statement_id":1,"series":[{"name":"databases","columns":["name"]}]}]}`,
+		query(http.PostForm(createURL(listener, "http", "/query", ""), url.Values{"q": {"CREATE DATABASE mydb; SHOW DATABASES"}})))
+}
+
+func TestUptimeAndLastWrite(t *testing.T) {
+	listener := newTestListener()
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	// Pretend the listener was started a minute ago.
+	listener.started = listener.started.Add(-time.Minute)
+	require.NoError(t, listener.Gather(acc))
+	require.True(t, listener.uptime.Get() >= 60)
+	require.True(t, listener.sinceLastWrite.Get() >= 60)
+
+	resp, err := http.Post(createURL(listener, "http", "/write", ""), "", bytes.NewBuffer([]byte(testMsg)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+
+	require.NoError(t, listener.Gather(acc))
+	require.Equal(t, int64(0), listener.sinceLastWrite.Get())
+
+	// And idle for ten seconds since.
+	listener.lastWriteMu.Lock()
+	listener.lastWrite = listener.lastWrite.Add(-10 * time.Second)
+	listener.lastWriteMu.Unlock()
+	require.NoError(t, listener.Gather(acc))
+	require.Equal(t, int64(10), listener.sinceLastWrite.Get())
+	require.True(t, listener.uptime.Get() >= 60)
+}
+
+func TestErrorResponseBytes(t *testing.T) {
+	listener := newTestListener()
+	listener.MaxBodySize = internal.Size{Size: 16}
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	tests := []struct {
+		name     string
+		body     string
+		expected string
+	}{
+		{
+			name: "bad request",
+			body: "cpu value=",
+			expected: "HTTP/1.1 400 Bad Request\r\n" +
+				"Content-Type: application/json\r\n" +
+				"X-Influxdb-Error: metric parse error: expected field at 1:11: \"cpu value=\"\r\n" +
+				"X-Influxdb-Version: 1.0\r\n" +
+				"Content-Length: 70\r\n" +
+				"\r\n" +
+				`{"error":"metric parse error: expected field at 1:11: \"cpu value=\""}`,
+		},
+		{
+			name: "too large",
+			body: "cpu value=1 1422568543702900257\n",
+			expected: "HTTP/1.1 413 Request Entity Too Large\r\n" +
+				"Content-Type: application/json\r\n" +
+				"X-Influxdb-Error: http: request body too large\r\n" +
+				"X-Influxdb-Version: 1.0\r\n" +
+				"Content-Length: 40\r\n" +
+				"\r\n" +
+				`{"error":"http: request body too large"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", listener.port))
+			require.NoError(t, err)
+			defer conn.Close()
+
+			_, err = fmt.Fprintf(conn, "POST /write HTTP/1.1\r\nHost: localhost\r\nConnection: close\r\nContent-Length: %d\r\n\r\n%s", len(tt.body), tt.body)
+			require.NoError(t, err)
+			raw, err := ioutil.ReadAll(conn)
+			require.NoError(t, err)
+
+			// The Date header is the only part that changes.
+			actual := regexp.MustCompile("Date: [^\r]*\r\n").ReplaceAllString(string(raw), "")
+			actual = strings.Replace(actual, "Connection: close\r\n", "", 1)
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	listener := newTestListener()
+	listener.EnableReadinessEndpoint = true
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	get := func(path string) int {
+		resp, err := http.Get(createURL(listener, "http", path, ""))
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	require.Equal(t, http.StatusOK, get("/health"))
+	require.Equal(t, http.StatusOK, get("/ready"))
+
+	// A write waiting on the accumulator for too long.
+	wr := &writeRequest{}
+	listener.accWaitingMu.Lock()
+	listener.accWaiting[wr] = time.Now().Add(-2 * time.Second)
+	listener.accWaitingMu.Unlock()
+	require.Equal(t, http.StatusServiceUnavailable, get("/ready"))
+	require.Equal(t, http.StatusOK, get("/health"))
+
+	listener.accWaitingMu.Lock()
+	delete(listener.accWaiting, wr)
+	listener.accWaitingMu.Unlock()
+	require.Equal(t, http.StatusOK, get("/ready"))
+
+	listener.MaintenanceMode = true
+	require.Equal(t, http.StatusServiceUnavailable, get("/ready"))
+	require.Equal(t, http.StatusOK, get("/health"))
+}
+
+func TestWriteMaxNewFieldsPerInterval(t *testing.T) {
+	listener := newTestListener()
+	listener.MaxNewFieldsPerInterval = 3
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	tooMany := listener.metricsTooManyNewFields.Get()
+
+	// The second and fourth metric would bring cpu over three new fields,
+	// mem has a limit of its own.
+	msg := "cpu a=1,b=2 0\ncpu c=3,d=4 0\ncpu a=5,c=6 0\ncpu d=7 0\nmem x=1,y=2,z=3 0\n"
+	resp, err := http.Post(createURL(listener, "http", "/write", ""), "", bytes.NewBuffer([]byte(msg)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, http.StatusBadRequest, resp.StatusCode)
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric("cpu",
+			map[string]string{},
+			map[string]interface{}{"a": 1.0, "b": 2.0},
+			time.Unix(0, 0)),
+		testutil.MustMetric("cpu",
+			map[string]string{},
+			map[string]interface{}{"a": 5.0, "c": 6.0},
+			time.Unix(0, 0)),
+		testutil.MustMetric("mem",
+			map[string]string{},
+			map[string]interface{}{"x": 1.0, "y": 2.0, "z": 3.0},
+			time.Unix(0, 0)),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics())
+	require.Equal(t, tooMany+2, listener.metricsTooManyNewFields.Get())
+
+	// Known fields are always accepted, new ones again after a gather.
+	msg = "cpu a=1,b=2,c=3 0\n"
+	resp, err = http.Post(createURL(listener, "http", "/write", ""), "", bytes.NewBuffer([]byte(msg)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, http.StatusNoContent, resp.StatusCode)
+
+	require.NoError(t, listener.Gather(acc))
+	resp, err = http.Post(createURL(listener, "http", "/write", ""), "", bytes.NewBuffer([]byte("cpu d=7 0\n")))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, http.StatusNoContent, resp.StatusCode)
+	require.Equal(t, tooMany+2, listener.metricsTooManyNewFields.Get())
+}
+
+// writeCRL writes a certificate revocation list of the test CA, revoking the
+// given certificates, to path.
+func writeCRL(t *testing.T, path string, revoked ...*x509.Certificate) {
+	caCert, err := tls.LoadX509KeyPair(pki.CACertPath(), "../../../testutil/pki/cakey.pem")
+	require.NoError(t, err)
+	ca, err := x509.ParseCertificate(caCert.Certificate[0])
+	require.NoError(t, err)
+
+	var list []pkix.RevokedCertificate
+	for _, cert := range revoked {
+		list = append(list, pkix.RevokedCertificate{SerialNumber: cert.SerialNumber, RevocationTime: time.Now()})
+	}
+	der, err := ca.CreateCRL(rand.Reader, caCert.PrivateKey, list, time.Now(), time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der}), 0600))
+}
+
+func TestWriteSecureRevokedClientCert(t *testing.T) {
+	clientCert, err := tls.LoadX509KeyPair(pki.ClientCertPath(), pki.ClientKeyPath())
+	require.NoError(t, err)
+	client, err := x509.ParseCertificate(clientCert.Certificate[0])
+	require.NoError(t, err)
+
+	dir, err := ioutil.TempDir("", "influxdb_listener")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	crlFile := filepath.Join(dir, "ca.crl")
+	writeCRL(t, crlFile, client)
+
+	listener := newTestSecureListener()
+	listener.TLSCRLFile = crlFile
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	revoked := listener.tlsRevokedCerts.Get()
+
+	_, err = getSecureClient().Post(createURL(listener, "https", "/write", "db=mydb"), "", bytes.NewBuffer([]byte(testMsg)))
+	require.Error(t, err)
+	require.Equal(t, revoked+1, listener.tlsRevokedCerts.Get())
+
+	// The changed list is picked up on the next gather.
+	writeCRL(t, crlFile)
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(crlFile, future, future))
+	require.NoError(t, listener.Gather(acc))
+
+	resp, err := getSecureClient().Post(createURL(listener, "https", "/write", "db=mydb"), "", bytes.NewBuffer([]byte(testMsg)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+	require.Equal(t, revoked+1, listener.tlsRevokedCerts.Get())
+}
+
+func TestInitInvalidCRLFile(t *testing.T) {
+	listener := newTestSecureListener()
+	listener.TLSCRLFile = "/nonexistent/ca.crl"
+	require.Error(t, listener.Init())
+
+	listener = newTestSecureListener()
+	listener.TLSCRLFile = pki.CACertPath()
+	require.Error(t, listener.Init())
+}
+
+func TestWriteFlushInterval(t *testing.T) {
+	listener := newTestListener()
+	listener.FlushInterval.Duration = 100 * time.Millisecond
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	resp, err := http.Post(createURL(listener, "http", "/write", ""), "", bytes.NewBuffer([]byte(testMsgs)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+	require.EqualValues(t, 0, acc.NMetrics())
+
+	acc.Wait(5)
+	require.EqualValues(t, 5, acc.NMetrics())
+}
+
+func TestWriteFlushMaxMetrics(t *testing.T) {
+	listener := newTestListener()
+	listener.FlushInterval.Duration = time.Hour
+	listener.FlushMaxMetrics = 2
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	resp, err := http.Post(createURL(listener, "http", "/write", ""), "", bytes.NewBuffer([]byte(testMsgs)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+	require.EqualValues(t, 4, acc.NMetrics())
+}
+
+func TestWriteFlushOnStop(t *testing.T) {
+	listener := newTestListener()
+	listener.FlushInterval.Duration = time.Hour
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+
+	resp, err := http.Post(createURL(listener, "http", "/write", ""), "", bytes.NewBuffer([]byte(testMsgs)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+	require.EqualValues(t, 0, acc.NMetrics())
+
+	listener.Stop()
+	require.EqualValues(t, 5, acc.NMetrics())
+}
+
+func TestWriteRateLimitResponse(t *testing.T) {
+	listener := newTestListener()
+	listener.GlobalRateLimit = 1
+	listener.RateLimitStatusCode = http.StatusServiceUnavailable
+	listener.RateLimitRetryAfter.Duration = 30 * time.Second
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	resp, err := http.Post(createURL(listener, "http", "/write", ""), "", bytes.NewBuffer([]byte(testMsg)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+
+	resp, err = http.Post(createURL(listener, "http", "/write", ""), "", bytes.NewBuffer([]byte(testMsg)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 503, resp.StatusCode)
+	require.Equal(t, "30", resp.Header.Get("Retry-After"))
+}
+
+func TestInitInvalidRateLimitStatusCode(t *testing.T) {
+	for _, code := range []int{200, 302, 600} {
+		listener := newTestListener()
+		listener.RateLimitStatusCode = code
+		require.Error(t, listener.Init())
+	}
+}
+
+func TestWriteNormalizeBooleans(t *testing.T) {
+	listener := newTestListener()
+	listener.NormalizeBooleans = true
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	msg := `cpu a=t,b="YES",c="on",d="1",e="False",f="n",g="OFF",h="0",i="maybe",j=1i 0` + "\n"
+	resp, err := http.Post(createURL(listener, "http", "/write", ""), "", bytes.NewBuffer([]byte(msg)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric("cpu",
+			map[string]string{},
+			map[string]interface{}{
+				"a": true,
+				"b": true,
+				"c": true,
+				"d": true,
+				"e": false,
+				"f": false,
+				"g": false,
+				"h": false,
+				"i": "maybe",
+				"j": int64(1),
+			},
+			time.Unix(0, 0)),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics())
+}
+
+func TestWriteMaxConcurrentWritesPerDatabase(t *testing.T) {
+	listener := newTestListener()
+	listener.MaxConcurrentWritesPerDB = 1
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	// Take the place of a write to mydb being handled.
+	require.True(t, listener.acquireDBWrite("mydb"))
+
+	resp, err := http.Post(createURL(listener, "http", "/write", "db=mydb"), "", bytes.NewBuffer([]byte(testMsg)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+	// Other databases are not held up.
+	resp, err = http.Post(createURL(listener, "http", "/write", "db=otherdb"), "", bytes.NewBuffer([]byte(testMsg)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+	require.Equal(t, map[string]int{"mydb": 1}, listener.dbWrites)
+
+	listener.releaseDBWrite("mydb")
+	require.Empty(t, listener.dbWrites)
+	resp, err = http.Post(createURL(listener, "http", "/write", "db=mydb"), "", bytes.NewBuffer([]byte(testMsg)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+}
+
+func TestWriteEmitParseFailuresAsMetrics(t *testing.T) {
+	listener := newTestListener()
+	listener.EmitParseFailuresAsMetrics = true
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	resp, err := http.Post(createURL(listener, "http", "/write", "db=mydb"), "", bytes.NewBuffer([]byte("cpu value=1 0\ncpu value= 0\n")))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 400, resp.StatusCode)
+
+	require.True(t, acc.HasMeasurement("cpu"))
+	require.True(t, acc.HasTag("telegraf_influxdb_listener_parse_failure", "client_ip"))
+	require.True(t, acc.HasTag("telegraf_influxdb_listener_parse_failure", "db"))
+	require.True(t, acc.HasInt64Field("telegraf_influxdb_listener_parse_failure", "line"))
+	errString, ok := acc.StringField("telegraf_influxdb_listener_parse_failure", "error")
+	require.True(t, ok)
+	require.Contains(t, errString, `"cpu value="`)
+
+	// The metrics are rate limited.
+	msg := strings.Repeat("cpu value= 0\n", 2*parseFailureMetricRate)
+	resp, err = http.Post(createURL(listener, "http", "/write", "db=mydb"), "", bytes.NewBuffer([]byte(msg)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 400, resp.StatusCode)
+	require.True(t, acc.NMetrics() < 1+1+2*parseFailureMetricRate)
+}
+
+func TestWriteMaxHeaderCount(t *testing.T) {
+	listener := newTestListener()
+	listener.MaxHeaderCount = 10
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	post := func(headers int) int {
+		req, err := http.NewRequest("POST", createURL(listener, "http", "/write", ""), bytes.NewBuffer([]byte(testMsg)))
+		require.NoError(t, err)
+		for i := 0; i < headers; i++ {
+			req.Header.Add("X-Padding", strconv.Itoa(i))
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// The client adds User-Agent, Content-Length and Accept-Encoding.
+	require.EqualValues(t, 204, post(5))
+	require.EqualValues(t, http.StatusRequestHeaderFieldsTooLarge, post(20))
+	require.EqualValues(t, 1, acc.NMetrics())
+}
+
+func TestWriteOutputPrecision(t *testing.T) {
+	listener := newTestListener()
+	listener.OutputPrecision = "ms"
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	for _, precision := range []string{"ns", "u"} {
+		resp, err := http.Post(createURL(listener, "http", "/write", "precision="+precision), "", bytes.NewBuffer([]byte("cpu value=1 1422568543702900\n")))
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.EqualValues(t, 204, resp.StatusCode)
+	}
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric("cpu",
+			map[string]string{},
+			map[string]interface{}{"value": 1.0},
+			time.Unix(0, 1422568543000000)),
+		testutil.MustMetric("cpu",
+			map[string]string{},
+			map[string]interface{}{"value": 1.0},
+			time.Unix(0, 1422568543702000000)),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics())
+}
+
+func TestInitInvalidOutputPrecision(t *testing.T) {
+	listener := newTestListener()
+	listener.OutputPrecision = "d"
+	require.Error(t, listener.Init())
+}
+
+func TestWriteRedactTagsInLogs(t *testing.T) {
+	log := &recordingLogger{}
+	listener := newTestListener()
+	listener.Log = log
+	listener.RedactTagsInLogs = []string{"user", "email"}
+	listener.ErrorVerbosity = "minimal"
+	listener.EmitParseFailuresAsMetrics = true
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	msg := "cpu,user=alice,region=west value=1 0\n" +
+		"cpu,user=al\\ ice,email=alice@example.com,region=west value= 0\n"
+	resp, err := http.Post(createURL(listener, "http", "/write", ""), "", bytes.NewBuffer([]byte(msg)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 400, resp.StatusCode)
+
+	logs := strings.Join(log.Debugs(), "\n")
+	require.Contains(t, logs, "user=***,email=***,region=west")
+	require.NotContains(t, logs, "alice")
+	require.NotContains(t, logs, "ice,")
+
+	errString, ok := acc.StringField("telegraf_influxdb_listener_parse_failure", "error")
+	require.True(t, ok)
+	require.Contains(t, errString, "user=***,email=***,region=west")
+
+	// Only the logs are redacted, not the metrics.
+	require.True(t, acc.HasTag("cpu", "user"))
+}
+
+func TestWriteTrailingSlash(t *testing.T) {
+	listener := newTestListener()
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Post(createURL(listener, "http", "/write/", "db=mydb"), "", bytes.NewBuffer([]byte(testMsg)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+	acc.Wait(1)
+
+	resp, err = client.Get(createURL(listener, "http", "/ping/", ""))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+
+	// Only the slash is ignored, not what follows it.
+	resp, err = client.Post(createURL(listener, "http", "/write/other", "db=mydb"), "", bytes.NewBuffer([]byte(testMsg)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 404, resp.StatusCode)
+}
+
+func TestWriteMaxMetricsPerRequest(t *testing.T) {
+	listener := newTestListener()
+	listener.MaxMetricsPerRequest = 10
+	listener.EnableNDJSONEndpoint = true
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	bytesRecv := listener.bytesRecv.Get()
+
+	// Far more metrics than the limit, of which only the first few are read.
+	msg := strings.Repeat("cpu value=1 0\n", 1000000)
+	resp, err := http.Post(createURL(listener, "http", "/write", ""), "", strings.NewReader(msg))
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 400, resp.StatusCode)
+	require.Contains(t, string(body), "too many metrics")
+	require.EqualValues(t, 10, acc.NMetrics())
+	require.True(t, listener.bytesRecv.Get()-bytesRecv < int64(len(msg))/10)
+
+	msg = strings.Repeat(`{"name":"cpu","fields":{"value":1}}`+"\n", 20)
+	resp, err = http.Post(createURL(listener, "http", "/write/ndjson", ""), "", strings.NewReader(msg))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 400, resp.StatusCode)
+	require.EqualValues(t, 20, acc.NMetrics())
+}
+
+func TestWriteResponseHeaders(t *testing.T) {
+	listener := newTestListener()
+	listener.ResponseHeaders = map[string]string{
+		"Cache-Control": "no-store",
+		"X-Served-By":   "telegraf",
+	}
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	resp, err := http.Post(createURL(listener, "http", "/write", ""), "", bytes.NewBuffer([]byte(testMsg)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+	require.Equal(t, "no-store", resp.Header.Get("Cache-Control"))
+	require.Equal(t, "telegraf", resp.Header.Get("X-Served-By"))
+}
+
+func TestInitInvalidResponseHeaders(t *testing.T) {
+	for _, headers := range []map[string]string{
+		{"": "value"},
+		{"Bad Header": "value"},
+		{"X-Header": "line\r\nInjected: value"},
+	} {
+		listener := newTestListener()
+		listener.ResponseHeaders = headers
+		require.Error(t, listener.Init())
+	}
+}
+
+func TestWriteRequireContentLengthForCompressed(t *testing.T) {
+	listener := newTestListener()
+	listener.RequireContentLengthForCompressed = true
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write([]byte(testMsg))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+
+	post := func(chunked bool, encoding string) int {
+		// A body of unknown size is sent chunked.
+		var body io.Reader = bytes.NewReader(buf.Bytes())
+		if chunked {
+			body = ioutil.NopCloser(body)
+		}
+		req, err := http.NewRequest("POST", createURL(listener, "http", "/write", ""), body)
+		require.NoError(t, err)
+		req.Header.Set("Content-Encoding", encoding)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	require.EqualValues(t, http.StatusLengthRequired, post(true, "gzip"))
+	require.EqualValues(t, 204, post(false, "gzip"))
+	require.EqualValues(t, 1, acc.NMetrics())
+}
+
+func TestWriteMeasurementSuffixParam(t *testing.T) {
+	listener := newTestListener()
+	listener.MeasurementSuffixParam = "env"
+	listener.StrictQueryParams = true
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	resp, err := http.Post(createURL(listener, "http", "/write", "db=mydb&env=staging"), "", bytes.NewBuffer([]byte(testMsg)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+
+	acc.Wait(1)
+	acc.AssertContainsTaggedFields(t, "cpu_load_short_staging",
+		map[string]interface{}{"value": float64(12)},
+		map[string]string{"host": "server01"},
+	)
+
+	resp, err = http.Post(createURL(listener, "http", "/write", "db=mydb"), "", bytes.NewBuffer([]byte(testMsg)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+
+	acc.Wait(2)
+	acc.AssertContainsTaggedFields(t, "cpu_load_short",
+		map[string]interface{}{"value": float64(12)},
+		map[string]string{"host": "server01"},
+	)
+}
+
+func TestTrackClientIPs(t *testing.T) {
+	listener := newTestListener()
+	listener.TrackClientIPs = true
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	for i := 0; i < 50; i++ {
+		// Each client sends several requests from different ports.
+		for port := 1000; port < 1003; port++ {
+			req := httptest.NewRequest("GET", "/ping", nil)
+			req.RemoteAddr = fmt.Sprintf("10.0.%d.%d:%d", i/10, i%10, port)
+			res := httptest.NewRecorder()
+			listener.ServeHTTP(res, req)
+			require.EqualValues(t, 204, res.Code)
+		}
+	}
+
+	require.NoError(t, listener.Gather(acc))
+	require.EqualValues(t, 50, listener.distinctClientIPs.Get())
+
+	// The count starts over at each gather.
+	req := httptest.NewRequest("GET", "/ping", nil)
+	req.RemoteAddr = "10.0.0.1:1000"
+	listener.ServeHTTP(httptest.NewRecorder(), req)
+	require.NoError(t, listener.Gather(acc))
+	require.EqualValues(t, 1, listener.distinctClientIPs.Get())
+}
+
+func TestDistinctCounterEstimate(t *testing.T) {
+	counter := newDistinctCounter()
+	for i := 0; i < 20000; i++ {
+		counter.add(fmt.Sprintf("10.%d.%d.%d", i/65536, i/256%256, i%256))
+	}
+	require.InDelta(t, 20000, counter.reset(), 1000)
+	require.EqualValues(t, 0, counter.reset())
+}
+
+func TestWriteGzipErrorResponses(t *testing.T) {
+	listener := newTestListener()
+	listener.GzipErrorResponses = true
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	gzipped := func(body string) []byte {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		_, err := gz.Write([]byte(body))
+		require.NoError(t, err)
+		require.NoError(t, gz.Close())
+		return buf.Bytes()
+	}
+	// The client must not ask for gzip by itself.
+	client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+	post := func(body []byte, acceptEncoding string) *http.Response {
+		req, err := http.NewRequest("POST", createURL(listener, "http", "/write", "db=mydb"), bytes.NewBuffer(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Encoding", "gzip")
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	// A gzipped write succeeds without a body to compress.
+	resp := post(gzipped(testMsg), "gzip")
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+	require.Empty(t, resp.Header.Get("Content-Encoding"))
+	acc.Wait(1)
+	acc.AssertContainsTaggedFields(t, "cpu_load_short",
+		map[string]interface{}{"value": float64(12)},
+		map[string]string{"host": "server01"},
+	)
+
+	// The errors of gzipped bodies are gzipped, for parse errors and for
+	// bodies that fail to decompress.
+	for _, body := range [][]byte{gzipped(badMsg), []byte("not gzip")} {
+		resp = post(body, "deflate, gzip;q=0.5")
+		require.EqualValues(t, 400, resp.StatusCode)
+		require.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+		gz, err := gzip.NewReader(resp.Body)
+		require.NoError(t, err)
+		errBody, err := ioutil.ReadAll(gz)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Contains(t, string(errBody), `{"error":`)
+		require.NotEmpty(t, resp.Header.Get("X-Influxdb-Error"))
+	}
+
+	// Clients not accepting gzip get plain errors.
+	for _, acceptEncoding := range []string{"", "identity", "gzip;q=0"} {
+		resp = post(gzipped(badMsg), acceptEncoding)
+		errBody, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.EqualValues(t, 400, resp.StatusCode)
+		require.Empty(t, resp.Header.Get("Content-Encoding"))
+		require.Contains(t, string(errBody), `{"error":`)
+	}
+}
+
+func TestCompleteLinesReader(t *testing.T) {
+	longLine := "cpu_load_short,host=" + strings.Repeat("x", 20000) + " value=1\n"
+	body := testMsgs + longLine + testMsg + "cpu_load_short,host=server07 value=1"
+
+	readAll := func(r io.Reader) (string, *completeLinesReader) {
+		// Read a few bytes at a time, from a reader returning little data at
+		// a time, so that lines are split across chunks and reads.
+		lines := &completeLinesReader{r: iotest.HalfReader(r)}
+		data, err := ioutil.ReadAll(iotest.OneByteReader(lines))
+		require.NoError(t, err)
+		return string(data), lines
+	}
+
+	// The whole body is passed on at the end of the stream.
+	data, lines := readAll(strings.NewReader(body))
+	require.Equal(t, body, data)
+	require.NoError(t, lines.err)
+
+	// The incomplete last line is dropped when reading fails.
+	readErr := errors.New("connection reset")
+	r, w := io.Pipe()
+	go func() {
+		w.Write([]byte(body))
+		w.CloseWithError(readErr)
+	}()
+	data, lines = readAll(r)
+	require.Equal(t, testMsgs+longLine+testMsg, data)
+	require.Equal(t, readErr, lines.err)
+}
+
+func TestWriteImmediatelyAfterStart(t *testing.T) {
+	listener := newTestListener()
+	listener.FlushInterval = internal.Duration{Duration: time.Hour}
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+
+	// Every request sent as soon as Start returns is handled in full.
+	const writers = 50
+	statuses := make(chan int, writers)
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			body := fmt.Sprintf("cpu_load_short,host=server%02d value=12.0\n", i)
+			resp, err := http.Post(createURL(listener, "http", "/write", "db=mydb"), "", bytes.NewBufferString(body))
+			if err != nil {
+				statuses <- 0
+				return
+			}
+			resp.Body.Close()
+			statuses <- resp.StatusCode
+		}(i)
+	}
+	wg.Wait()
+	close(statuses)
+	for status := range statuses {
+		require.EqualValues(t, 204, status)
+	}
+
+	// The buffered metrics are flushed on Stop.
+	listener.Stop()
+	require.Len(t, acc.GetTelegrafMetrics(), writers)
+}
+
+func TestWriteGzipErrorResponsesTooLarge(t *testing.T) {
+	listener := newTestListener()
+	listener.GzipErrorResponses = true
+	listener.QueryMaxBodySize = internal.Size{Size: 1024}
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	// Without a Content-Length, the body is only found to be too large
+	// while reading it, after which the connection is closed.
+	body := strings.NewReader(strings.Repeat(testMsg, 100))
+	req, err := http.NewRequest("POST", createURL(listener, "http", "/query", ""), ioutil.NopCloser(body))
+	require.NoError(t, err)
+	req.Header.Set("Accept-Encoding", "gzip")
+	client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.EqualValues(t, 413, resp.StatusCode)
+	require.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+	require.True(t, resp.Close)
+}
+
+func TestGzipErrorWriterInterfaces(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	var res http.ResponseWriter = &gzipErrorWriter{ResponseWriter: recorder}
+	require.Equal(t, recorder, unwrapResponseWriter(res))
+
+	// The recorder cannot be hijacked, but can be flushed.
+	_, ok := res.(http.Hijacker)
+	require.True(t, ok)
+	_, _, err := res.(http.Hijacker).Hijack()
+	require.Error(t, err)
+
+	res.WriteHeader(http.StatusBadRequest)
+	res.Write([]byte(`{"error":"bad"}`))
+	flusher, ok := res.(http.Flusher)
+	require.True(t, ok)
+	flusher.Flush()
+	require.True(t, recorder.Flushed)
+	require.True(t, recorder.Body.Len() > 0)
+}
+
+func TestRetryAfterSeconds(t *testing.T) {
+	tests := []struct {
+		retryAfter time.Duration
+		expected   string
+	}{
+		{0, "1"},
+		{time.Millisecond, "1"},
+		{500 * time.Millisecond, "1"},
+		{time.Second, "1"},
+		{1500 * time.Millisecond, "2"},
+		{time.Minute, "60"},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.expected, retryAfterSeconds(tt.retryAfter), tt.retryAfter.String())
+	}
+}
+
+func TestMaintenanceSubSecondRetryAfter(t *testing.T) {
+	listener := newTestListener()
+	listener.MaintenanceMode = true
+	listener.MaintenanceRetryAfter = internal.Duration{Duration: 200 * time.Millisecond}
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	resp, err := http.Post(createURL(listener, "http", "/write", ""), "", bytes.NewBuffer([]byte(testMsg)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 503, resp.StatusCode)
+	require.Equal(t, "1", resp.Header.Get("Retry-After"))
+}
+
+func TestWriteErrorVerbosityEndpoints(t *testing.T) {
+	log := &recordingLogger{}
+	listener := newTestListener()
+	listener.Log = log
+	listener.ErrorVerbosity = "minimal"
+	listener.EnableCSVEndpoint = true
+	listener.EnablePrometheusEndpoint = true
+	listener.EnableNDJSONEndpoint = true
+	listener.RejectEmptyWrites = true
+	listener.StrictQueryParams = true
+
+	acc := &testutil.NopAccumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	tests := []struct {
+		path   string
+		query  string
+		header string
+	}{
+		{path: "/write", query: "foo=bar"},
+		{path: "/write", header: "s"},
+		{path: "/write", header: "x"},
+		{path: "/write"},
+		{path: "/write/csv"},
+		{path: "/write/prometheus"},
+		{path: "/write/ndjson"},
+	}
+
+	for _, tt := range tests {
+		req, err := http.NewRequest("POST", createURL(listener, "http", tt.path, tt.query), nil)
+		require.NoError(t, err)
+		if tt.header != "" {
+			req.Header.Set("X-Influx-Precision", tt.header)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.EqualValues(t, 400, resp.StatusCode, tt.path)
+		require.Equal(t, `{"error":"http: bad request"}`, string(body), tt.path)
+	}
+
+	// The errors hidden from the clients are still logged.
+	logs := strings.Join(log.Debugs(), "\n")
+	require.Contains(t, logs, `Bad request: unknown query parameter "foo"`)
+	require.Contains(t, logs, `Bad request: invalid precision "x"`)
+	require.Contains(t, logs, "Bad request: http: empty write")
+}
+
+func TestWriteNDJSONPrecisionHeader(t *testing.T) {
+	listener := newTestListener()
+	listener.EnableNDJSONEndpoint = true
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	post := func(query, precision string) int {
+		req, err := http.NewRequest("POST", createURL(listener, "http", "/write/ndjson", query), bytes.NewBuffer([]byte(`{"name":"cpu","fields":{"value":1},"timestamp":1422568543}`)))
+		require.NoError(t, err)
+		req.Header.Set("X-Influx-Precision", precision)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	require.Equal(t, http.StatusNoContent, post("", "s"))
+	// The query parameter takes precedence.
+	require.Equal(t, http.StatusNoContent, post("precision=ms", "s"))
+	require.Equal(t, http.StatusBadRequest, post("", "seconds"))
+
+	metrics := acc.GetTelegrafMetrics()
+	require.Len(t, metrics, 2)
+	require.Equal(t, time.Unix(1422568543, 0), metrics[0].Time())
+	require.Equal(t, time.Unix(0, 1422568543*int64(time.Millisecond)), metrics[1].Time())
+}
+
+func TestWriteAutoDetectPrecisionOverflow(t *testing.T) {
+	listener := newTestListener()
+	listener.AutoDetectPrecision = true
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	body := "cpu value=1 9000000000000000000\ncpu value=2 1422568543\n"
+	for _, precision := range []string{"s", "ms"} {
+		resp, err := http.Post(createURL(listener, "http", "/write", "precision="+precision), "", bytes.NewBuffer([]byte(body)))
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.EqualValues(t, 400, resp.StatusCode)
+	}
+
+	metrics := acc.GetTelegrafMetrics()
+	require.Len(t, metrics, 2)
+	for _, m := range metrics {
+		require.Equal(t, 2.0, m.Fields()["value"])
+		require.Equal(t, time.Unix(1422568543, 0).UnixNano(), m.Time().UnixNano())
+	}
+}
+
+func TestWriteHMACSignatureGzipBomb(t *testing.T) {
+	listener := newTestListener()
+	listener.HMACSecret = "secret"
+	listener.EnableNDJSONEndpoint = true
+	listener.MaxBodySize = internal.Size{Size: 64 * 1024}
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Init())
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	// Well within max_body_size compressed, far beyond it decompressed.
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write(make([]byte, 16*1024*1024))
+	gz.Close()
+	require.True(t, int64(buf.Len()) < listener.MaxBodySize.Size)
+
+	for _, path := range []string{"/write", "/write/ndjson"} {
+		req, err := http.NewRequest("POST", createURL(listener, "http", path, ""), bytes.NewReader(buf.Bytes()))
+		require.NoError(t, err)
+		req.Header.Set("Content-Encoding", "gzip")
+		req.Header.Set("X-Signature", "00")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.EqualValues(t, 413, resp.StatusCode)
+	}
+}
+
 const hugeMetric = `super_long_metric,foo=bar clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=1i,connected_slaves=0i,evicted_keys=0i,expired_keys=0i,instantaneous_ops_per_sec=0i,keyspace_hitrate=0,keyspace_hits=0i,keyspace_misses=2i,latest_fork_usec=0i,master_repl_offset=0i,mem_fragmentation_ratio=3.58,pubsub_channels=0i,pubsub_patterns=0i,rdb_changes_since_last_save=0i,repl_backlog_active=0i,repl_backlog_histlen=0i,repl_backlog_size=1048576i,sync_full=0i,sync_partial_err=0i,sync_partial_ok=0i,total_commands_processed=4i,total_connections_received=2i,uptime=869i,used_cpu_sys=0.07,used_cpu_sys_children=0,used_cpu_user=0.1,used_cpu_user_children=0,used_memory=502048i,used_memory_lua=33792i,used_memory_peak=501128i,used_memory_rss=1798144i,clients=42i,connected_slaves=43i,evicted_keys=44i,expired_keys=45i,instantaneous_ops_per_sec=46i,keyspace_hitrate=47,keyspace_hits=48i,keyspace_misses=49i,latest_fork_usec=50i,master_repl_offset=51i,mem_fragmentation_ratio=52.58,pubsub_channels=53i,pubsub_patterns=54i,rdb_changes_since_last_save=55i,repl_backlog_active=56i,repl_backlog_histlen=57i,repl_backlog_size=58i,sync_full=59i,sync_partial_err=60i,sync_partial_ok=61i,total_commands_processed=62i,total_connections_received=63i,uptime=64i,used_cpu_sys=65.07,used_cpu_sys_children=66,used_cpu_user=67.1,used_cpu_user_children=68,used_memory=692048i,used_memory_lua=70792i,used_memory_peak=711128i,used_memory_rss=7298144i
 `
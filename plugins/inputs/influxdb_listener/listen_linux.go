@@ -0,0 +1,69 @@
+// +build linux
+
+package influxdb_listener
+
+import (
+	"net"
+	"os"
+	"syscall"
+)
+
+// listenTCP opens a TCP listener on address with the given accept backlog.
+// The standard library always uses the system maximum, so the socket is set
+// up by hand.
+func listenTCP(address string, backlog int) (net.Listener, error) {
+	addr, err := net.ResolveTCPAddr("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	if ip4 := addr.IP.To4(); ip4 != nil {
+		sa4 := &syscall.SockaddrInet4{Port: addr.Port}
+		copy(sa4.Addr[:], ip4)
+		return listenSocket(syscall.AF_INET, sa4, false, backlog)
+	}
+
+	sa6 := &syscall.SockaddrInet6{Port: addr.Port}
+	copy(sa6.Addr[:], addr.IP.To16())
+	// Without a host, accept IPv4 connections as well, as net.Listen does.
+	listener, err := listenSocket(syscall.AF_INET6, sa6, addr.IP == nil, backlog)
+	if err != nil && addr.IP == nil && ipv6Unavailable(err) {
+		return listenSocket(syscall.AF_INET, &syscall.SockaddrInet4{Port: addr.Port}, false, backlog)
+	}
+	return listener, err
+}
+
+// listenSocket opens a listening socket of the family bound to sa.
+func listenSocket(family int, sa syscall.Sockaddr, dualStack bool, backlog int) (net.Listener, error) {
+	fd, err := syscall.Socket(family, syscall.SOCK_STREAM|syscall.SOCK_CLOEXEC, syscall.IPPROTO_TCP)
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+	file := os.NewFile(uintptr(fd), "influxdb_listener")
+	defer file.Close()
+
+	if err := syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1); err != nil {
+		return nil, os.NewSyscallError("setsockopt", err)
+	}
+	if dualStack {
+		if err := syscall.SetsockoptInt(fd, syscall.IPPROTO_IPV6, syscall.IPV6_V6ONLY, 0); err != nil {
+			return nil, os.NewSyscallError("setsockopt", err)
+		}
+	}
+	if err := syscall.Bind(fd, sa); err != nil {
+		return nil, os.NewSyscallError("bind", err)
+	}
+	if err := syscall.Listen(fd, backlog); err != nil {
+		return nil, os.NewSyscallError("listen", err)
+	}
+
+	return net.FileListener(file)
+}
+
+// ipv6Unavailable returns whether err is from a host without IPv6 support.
+func ipv6Unavailable(err error) bool {
+	if err, ok := err.(*os.SyscallError); ok {
+		return err.Err == syscall.EAFNOSUPPORT || err.Err == syscall.EADDRNOTAVAIL
+	}
+	return false
+}
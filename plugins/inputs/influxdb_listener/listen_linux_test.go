@@ -0,0 +1,157 @@
+// +build linux
+
+package influxdb_listener
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestListenBacklog(t *testing.T) {
+	listener, err := listenTCP("127.0.0.1:0", 7)
+	require.NoError(t, err)
+	defer listener.Close()
+
+	raw, err := listener.(*net.TCPListener).SyscallConn()
+	require.NoError(t, err)
+	var accepting int
+	var sockErr error
+	err = raw.Control(func(fd uintptr) {
+		accepting, sockErr = syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_ACCEPTCONN)
+	})
+	require.NoError(t, err)
+	require.NoError(t, sockErr)
+	require.Equal(t, 1, accepting)
+
+	// The transmit queue of a listening socket is its backlog.  Some
+	// kernels, such as sandboxed ones, leave it at 0.
+	data, err := ioutil.ReadFile("/proc/net/tcp")
+	require.NoError(t, err)
+	local := fmt.Sprintf("0100007F:%04X", listener.Addr().(*net.TCPAddr).Port)
+	var backlog int64 = -1
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 4 && fields[1] == local && fields[3] == "0A" {
+			backlog, err = strconv.ParseInt(strings.Split(fields[4], ":")[0], 16, 64)
+			require.NoError(t, err)
+		}
+	}
+	require.NotEqual(t, int64(-1), backlog, "listening socket not found")
+	if backlog != 0 {
+		require.Equal(t, int64(7), backlog)
+	}
+}
+
+func TestListenNoHost(t *testing.T) {
+	listener, err := listenTCP(":0", 16)
+	require.NoError(t, err)
+	defer listener.Close()
+
+	// IPv4 connections are accepted whether the socket is IPv6 or not.
+	port := listener.Addr().(*net.TCPAddr).Port
+	client, err := net.Dial("tcp4", fmt.Sprintf("127.0.0.1:%d", port))
+	require.NoError(t, err)
+	defer client.Close()
+	conn, err := listener.Accept()
+	require.NoError(t, err)
+	conn.Close()
+}
+
+func TestIPv6Unavailable(t *testing.T) {
+	require.True(t, ipv6Unavailable(os.NewSyscallError("socket", syscall.EAFNOSUPPORT)))
+	require.True(t, ipv6Unavailable(os.NewSyscallError("bind", syscall.EADDRNOTAVAIL)))
+	require.False(t, ipv6Unavailable(os.NewSyscallError("bind", syscall.EADDRINUSE)))
+	require.False(t, ipv6Unavailable(syscall.EAFNOSUPPORT))
+}
+
+func TestTCPKeepAlivePeriod(t *testing.T) {
+	tests := []struct {
+		name      string
+		period    time.Duration
+		keepAlive int
+	}{
+		{
+			name:      "enabled",
+			period:    30 * time.Second,
+			keepAlive: 1,
+		},
+		{
+			name:      "disabled",
+			period:    0,
+			keepAlive: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner, err := net.Listen("tcp", "localhost:0")
+			require.NoError(t, err)
+			listener := &keepAliveListener{Listener: inner, period: tt.period}
+			defer listener.Close()
+
+			client, err := net.Dial("tcp", listener.Addr().String())
+			require.NoError(t, err)
+			defer client.Close()
+
+			conn, err := listener.Accept()
+			require.NoError(t, err)
+			defer conn.Close()
+
+			raw, err := conn.(*net.TCPConn).SyscallConn()
+			require.NoError(t, err)
+			var keepAlive, idle int
+			var sockErr error
+			err = raw.Control(func(fd uintptr) {
+				keepAlive, sockErr = syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_KEEPALIVE)
+				if sockErr == nil {
+					idle, sockErr = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_KEEPIDLE)
+				}
+			})
+			require.NoError(t, err)
+			require.NoError(t, sockErr)
+			require.Equal(t, tt.keepAlive, keepAlive)
+			if tt.period > 0 {
+				require.Equal(t, int(tt.period.Seconds()), idle)
+			}
+		})
+	}
+}
+
+func TestTCPNoDelay(t *testing.T) {
+	for _, noDelay := range []bool{true, false} {
+		t.Run(fmt.Sprintf("tcp_no_delay=%v", noDelay), func(t *testing.T) {
+			inner, err := net.Listen("tcp", "localhost:0")
+			require.NoError(t, err)
+			listener := &keepAliveListener{Listener: inner, noDelay: noDelay}
+			defer listener.Close()
+
+			client, err := net.Dial("tcp", listener.Addr().String())
+			require.NoError(t, err)
+			defer client.Close()
+
+			conn, err := listener.Accept()
+			require.NoError(t, err)
+			defer conn.Close()
+
+			raw, err := conn.(*net.TCPConn).SyscallConn()
+			require.NoError(t, err)
+			var value int
+			var sockErr error
+			err = raw.Control(func(fd uintptr) {
+				value, sockErr = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_NODELAY)
+			})
+			require.NoError(t, err)
+			require.NoError(t, sockErr)
+			require.Equal(t, noDelay, value != 0)
+		})
+	}
+}
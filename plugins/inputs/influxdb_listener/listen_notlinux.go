@@ -0,0 +1,12 @@
+// +build !linux
+
+package influxdb_listener
+
+import (
+	"net"
+)
+
+// listenTCP is only supported on Linux.
+func listenTCP(address string, backlog int) (net.Listener, error) {
+	return nil, errBacklogUnsupported
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/influxdata/telegraf/internal"
 	"github.com/influxdata/telegraf/internal/tls"
 	"github.com/influxdata/telegraf/plugins/inputs"
+	parser "github.com/influxdata/telegraf/plugins/parsers/prometheus"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
 )
@@ -338,9 +339,9 @@ func (p *Prometheus) gatherURL(u URLAndAddress, acc telegraf.Accumulator) error
 	}
 
 	if p.MetricVersion == 2 {
-		metrics, err = ParseV2(body, resp.Header)
+		metrics, err = parser.ParseV2(body, resp.Header)
 	} else {
-		metrics, err = Parse(body, resp.Header)
+		metrics, err = parser.Parse(body, resp.Header)
 	}
 
 	if err != nil {
@@ -1,3 +1,5 @@
+// Package prometheus parses the Prometheus exposition formats, as scraped by
+// the prometheus input and written to the influxdb_listener input.
 package prometheus
 
 // Parser inspired from